@@ -0,0 +1,207 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"go-ecommerce-api/database"
+	"go-ecommerce-api/models"
+	"go-ecommerce-api/services"
+	"go-ecommerce-api/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// errAttributeInUse is returned when changing an attribute would invalidate values products already have
+var errAttributeInUse = errors.New("attribute is in use")
+
+// CreateAttribute handles defining a new custom product attribute (admin only)
+// @Summary Create an attribute definition
+// @Description Define a custom product attribute. Enum attributes must list their allowed options.
+// @Tags Attributes
+// @Accept json
+// @Produce json
+// @Param attribute body object{key=string,label=string,type=string,options=[]string,required=bool} true "Attribute definition"
+// @Success 201 {object} utils.Response{data=models.AttributeDefinition} "Attribute created successfully"
+// @Failure 400 {object} utils.Response "Invalid request data"
+// @Failure 403 {object} utils.Response "Unauthorized action"
+// @Failure 409 {object} utils.Response "Attribute already exists"
+// @Failure 500 {object} utils.Response "Failed to create attribute"
+// @Router /attributes [post]
+func CreateAttribute(c *gin.Context) {
+	if !utils.IsAdmin(c) {
+		c.JSON(http.StatusForbidden, utils.GenerateResponse("failed", "You are not authorized to perform this action", nil, ""))
+		return
+	}
+
+	var input struct {
+		Key      string   `json:"key" binding:"required,max=64"`
+		Label    string   `json:"label" binding:"required"`
+		Type     string   `json:"type" binding:"required,oneof=string number enum boolean"`
+		Options  []string `json:"options"`
+		Required bool     `json:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, utils.GenerateResponse("failed", "Invalid request data", nil, err.Error()))
+		return
+	}
+
+	if (input.Type == services.AttributeEnum) != (len(input.Options) > 0) {
+		c.JSON(http.StatusBadRequest, utils.GenerateResponse("failed", "Invalid request data", nil, "options are required for enum attributes and not allowed otherwise"))
+		return
+	}
+
+	attribute := models.AttributeDefinition{
+		Key:      input.Key,
+		Label:    input.Label,
+		Type:     input.Type,
+		Options:  input.Options,
+		Required: input.Required,
+	}
+	result := database.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&attribute)
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, utils.GenerateResponse("failed", "Failed to create attribute", nil, result.Error.Error()))
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusConflict, utils.GenerateResponse("failed", "Attribute already exists", nil, "an attribute with key "+input.Key+" is already defined"))
+		return
+	}
+
+	c.JSON(http.StatusCreated, utils.GenerateResponse("success", "Attribute created successfully", attribute, ""))
+}
+
+// ListAttributes handles retrieving all attribute definitions
+// @Summary List attribute definitions
+// @Description Get every custom product attribute definition.
+// @Tags Attributes
+// @Produce json
+// @Success 200 {object} utils.Response{data=[]models.AttributeDefinition} "Attributes retrieved successfully"
+// @Failure 500 {object} utils.Response "Failed to fetch attributes"
+// @Router /attributes [get]
+func ListAttributes(c *gin.Context) {
+	var attributes []models.AttributeDefinition
+	if err := database.DB.Order("key").Find(&attributes).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, utils.GenerateResponse("failed", "Failed to fetch attributes", nil, err.Error()))
+		return
+	}
+
+	c.JSON(http.StatusOK, utils.GenerateResponse("success", "Attributes retrieved successfully", attributes, ""))
+}
+
+// UpdateAttribute handles updating an attribute definition (admin only)
+// @Summary Update an attribute definition
+// @Description Change the label, enum options or required flag of an attribute. The key and type cannot change.
+// @Description Making an attribute required while products lack a value, or removing an option products use, is rejected.
+// @Tags Attributes
+// @Accept json
+// @Produce json
+// @Param id path int true "Attribute ID"
+// @Param attribute body object{label=string,options=[]string,required=bool} true "Updated attribute definition"
+// @Success 200 {object} utils.Response{data=models.AttributeDefinition} "Attribute updated successfully"
+// @Failure 400 {object} utils.Response "Invalid request data"
+// @Failure 403 {object} utils.Response "Unauthorized action"
+// @Failure 404 {object} utils.Response "Attribute not found"
+// @Failure 409 {object} utils.Response "Attribute values in use conflict with the change"
+// @Failure 500 {object} utils.Response "Failed to update attribute"
+// @Router /attributes/{id} [put]
+func UpdateAttribute(c *gin.Context) {
+	if !utils.IsAdmin(c) {
+		c.JSON(http.StatusForbidden, utils.GenerateResponse("failed", "You are not authorized to perform this action", nil, ""))
+		return
+	}
+
+	var input struct {
+		Label    string   `json:"label" binding:"required"`
+		Options  []string `json:"options"`
+		Required bool     `json:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, utils.GenerateResponse("failed", "Invalid request data", nil, err.Error()))
+		return
+	}
+
+	var attribute models.AttributeDefinition
+	if err := database.DB.First(&attribute, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, utils.GenerateResponse("failed", "Attribute not found", nil, err.Error()))
+		return
+	}
+
+	if (attribute.Type == services.AttributeEnum) != (len(input.Options) > 0) {
+		c.JSON(http.StatusBadRequest, utils.GenerateResponse("failed", "Invalid request data", nil, "options are required for enum attributes and not allowed otherwise"))
+		return
+	}
+
+	updated := attribute
+	updated.Label = input.Label
+	updated.Options = input.Options
+	updated.Required = input.Required
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		// Re-read the definition locked, so concurrent edits are each checked against the other's result
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&attribute, attribute.ID).Error; err != nil {
+			return err
+		}
+		conflicts, err := services.AttributeChangeConflicts(tx, attribute, updated)
+		if err != nil {
+			return err
+		}
+		if conflicts > 0 {
+			return fmt.Errorf("%w: %d products have no value for it or use a removed option", errAttributeInUse, conflicts)
+		}
+		return tx.Save(&updated).Error
+	})
+	if errors.Is(err, errAttributeInUse) {
+		c.JSON(http.StatusConflict, utils.GenerateResponse("failed", "Attribute values in use conflict with the change", nil, err.Error()))
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, utils.GenerateResponse("failed", "Failed to update attribute", nil, err.Error()))
+		return
+	}
+
+	c.JSON(http.StatusOK, utils.GenerateResponse("success", "Attribute updated successfully", updated, ""))
+}
+
+// DeleteAttribute handles deleting an attribute definition (admin only)
+// @Summary Delete an attribute definition
+// @Description Delete an attribute definition and remove its values from every product.
+// @Tags Attributes
+// @Produce json
+// @Param id path int true "Attribute ID"
+// @Success 200 {object} utils.Response "Attribute deleted successfully"
+// @Failure 403 {object} utils.Response "Unauthorized action"
+// @Failure 404 {object} utils.Response "Attribute not found"
+// @Failure 500 {object} utils.Response "Failed to delete attribute"
+// @Router /attributes/{id} [delete]
+func DeleteAttribute(c *gin.Context) {
+	if !utils.IsAdmin(c) {
+		c.JSON(http.StatusForbidden, utils.GenerateResponse("failed", "You are not authorized to perform this action", nil, ""))
+		return
+	}
+
+	var attribute models.AttributeDefinition
+	if err := database.DB.First(&attribute, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, utils.GenerateResponse("failed", "Attribute not found", nil, err.Error()))
+		return
+	}
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.Product{}).Where("jsonb_exists(attributes, ?)", attribute.Key).
+			Updates(map[string]interface{}{
+				"attributes": gorm.Expr("attributes - ?", attribute.Key),
+				"version":    gorm.Expr("version + 1"),
+			}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&attribute).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, utils.GenerateResponse("failed", "Failed to delete attribute", nil, err.Error()))
+		return
+	}
+
+	c.JSON(http.StatusOK, utils.GenerateResponse("success", "Attribute deleted successfully", nil, ""))
+}
@@ -0,0 +1,93 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+
+	"go-ecommerce-api/database"
+	"go-ecommerce-api/models"
+	"go-ecommerce-api/services"
+	"go-ecommerce-api/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// SetBundleComponents sets the products a bundle is made of (admin only)
+// @Summary Set bundle components
+// @Description Replace the components of a product, turning it into a bundle sold at its own price.
+// @Description An empty list turns it back into a regular product. Bundles cannot contain other bundles.
+// @Description The If-Match header must carry the product's current ETag; stale writes are rejected.
+// @Tags Products
+// @Accept json
+// @Produce json
+// @Param id path int true "Bundle product ID"
+// @Param If-Match header string true "Current ETag of the product"
+// @Param components body object{components=[]object{product_id=int,quantity=int}} true "Components and the quantity of each in one bundle"
+// @Success 200 {object} utils.Response{data=models.Product} "Bundle components updated successfully"
+// @Failure 400 {object} utils.Response "Invalid bundle"
+// @Failure 403 {object} utils.Response "Unauthorized action"
+// @Failure 404 {object} utils.Response "Product not found"
+// @Failure 412 {object} utils.Response "Product was modified by another request"
+// @Failure 428 {object} utils.Response "If-Match header is required"
+// @Failure 500 {object} utils.Response "Failed to update bundle components"
+// @Router /products/{id}/components [put]
+func SetBundleComponents(c *gin.Context) {
+	if !utils.IsAdmin(c) {
+		c.JSON(http.StatusForbidden, utils.GenerateResponse("failed", "You are not authorized to perform this action", nil, ""))
+		return
+	}
+
+	var input struct {
+		Components []struct {
+			ProductID uint `json:"product_id" binding:"required"`
+			Quantity  int  `json:"quantity" binding:"required,gte=1"`
+		} `json:"components" binding:"dive"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, utils.GenerateResponse("failed", "Invalid request data", utils.FieldErrors(err), err.Error()))
+		return
+	}
+
+	var product models.Product
+	if err := database.DB.First(&product, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, utils.GenerateResponse("failed", "Product not found", nil, err.Error()))
+		return
+	}
+
+	if !checkIfMatch(c, utils.ETag(product.ID, product.Version)) {
+		return
+	}
+
+	components := make([]models.BundleComponent, len(input.Components))
+	for i, component := range input.Components {
+		components[i] = models.BundleComponent{ComponentID: component.ProductID, Quantity: component.Quantity}
+	}
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		// Lock the product so the version check and the component swap cannot interleave
+		var current models.Product
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id", "version").First(&current, product.ID).Error; err != nil {
+			return err
+		}
+		if current.Version != product.Version {
+			return errVersionConflict
+		}
+		return services.SetBundleComponents(tx, &product, components)
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, errVersionConflict):
+			c.JSON(http.StatusPreconditionFailed, utils.GenerateResponse("failed", "Product has been modified by another request", nil, err.Error()))
+		case errors.Is(err, services.ErrInvalidBundle):
+			c.JSON(http.StatusBadRequest, utils.GenerateResponse("failed", "Invalid bundle", nil, err.Error()))
+		default:
+			c.JSON(http.StatusInternalServerError, utils.GenerateResponse("failed", "Failed to update bundle components", nil, err.Error()))
+		}
+		return
+	}
+
+	c.Header("ETag", utils.ETag(product.ID, product.Version))
+	c.JSON(http.StatusOK, utils.GenerateResponse("success", "Bundle components updated successfully", product, ""))
+}
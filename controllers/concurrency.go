@@ -0,0 +1,67 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+
+	"go-ecommerce-api/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// errVersionConflict is returned when a versioned row was changed by another request
+var errVersionConflict = errors.New("resource was modified by another request")
+
+// checkIfMatch enforces the If-Match precondition for a write against the current ETag.
+// The ETag of any representation of the resource, e.g. a localized one, is accepted. It writes the error response and returns false when the write must not go ahead.
+func checkIfMatch(c *gin.Context, etag string) bool {
+	ifMatch := c.GetHeader("If-Match")
+	if ifMatch == "" {
+		c.JSON(http.StatusPreconditionRequired, utils.GenerateResponse("failed", "If-Match header is required", nil, "current ETag is "+etag))
+		return false
+	}
+
+	if !utils.MatchesResourceETag(ifMatch, etag) {
+		c.Header("ETag", etag)
+		c.JSON(http.StatusPreconditionFailed, utils.GenerateResponse("failed", "Resource has been modified by another request", nil, "current ETag is "+etag))
+		return false
+	}
+
+	return true
+}
+
+// notModified answers a conditional GET with 304 when If-None-Match matches the ETag
+func notModified(c *gin.Context, etag string) bool {
+	c.Header("ETag", etag)
+	ifNoneMatch := c.GetHeader("If-None-Match")
+	if ifNoneMatch != "" && utils.MatchesETag(ifNoneMatch, etag) {
+		c.Status(http.StatusNotModified)
+		return true
+	}
+
+	return false
+}
+
+// saveVersioned saves value only if its stored version is still the one it was read at,
+// bumping the version on success. version must point at value's Version field.
+func saveVersioned(tx *gorm.DB, value interface{}, version *uint) error {
+	expected := *version
+	*version = expected + 1
+
+	result := tx.Model(value).Where("version = ?", expected).
+		Select("*").Omit("id", "created_at", clause.Associations).
+		Updates(value)
+	if result.Error != nil {
+		*version = expected
+		return result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		*version = expected
+		return errVersionConflict
+	}
+
+	return nil
+}
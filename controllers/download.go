@@ -0,0 +1,236 @@
+package controllers
+
+import (
+	"errors"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"go-ecommerce-api/database"
+	"go-ecommerce-api/models"
+	"go-ecommerce-api/services"
+	"go-ecommerce-api/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// UploadProductFile attaches a downloadable file to a digital product (admin only)
+// @Summary Upload a product file
+// @Description Attach a file to a digital product. Buyers get download access once their order is paid;
+// @Description existing buyers of the product get access to the new file straight away.
+// @Tags Downloads
+// @Accept mpfd
+// @Produce json
+// @Param id path int true "Product ID"
+// @Param file formData file true "File to attach"
+// @Success 201 {object} utils.Response{data=models.ProductFile} "File uploaded successfully"
+// @Failure 400 {object} utils.Response "Invalid upload"
+// @Failure 403 {object} utils.Response "Unauthorized action"
+// @Failure 404 {object} utils.Response "Product not found"
+// @Failure 500 {object} utils.Response "Failed to store file"
+// @Router /products/{id}/files [post]
+func UploadProductFile(c *gin.Context) {
+	if !utils.IsAdmin(c) {
+		c.JSON(http.StatusForbidden, utils.GenerateResponse("failed", "You are not authorized to perform this action", nil, ""))
+		return
+	}
+
+	var product models.Product
+	if err := database.DB.First(&product, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, utils.GenerateResponse("failed", "Product not found", nil, err.Error()))
+		return
+	}
+
+	if !product.IsDigital {
+		c.JSON(http.StatusBadRequest, utils.GenerateResponse("failed", "Invalid upload", nil, "files can only be attached to digital products"))
+		return
+	}
+
+	header, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, utils.GenerateResponse("failed", "Invalid upload", nil, err.Error()))
+		return
+	}
+
+	path, err := services.ProductFilePath(product.ID, header.Filename)
+	if err == nil {
+		err = c.SaveUploadedFile(header, path)
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, utils.GenerateResponse("failed", "Failed to store file", nil, err.Error()))
+		return
+	}
+
+	file := models.ProductFile{
+		ProductID:   product.ID,
+		FileName:    filepath.Base(header.Filename),
+		StoragePath: path,
+		ContentType: header.Header.Get("Content-Type"),
+		Size:        header.Size,
+	}
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&file).Error; err != nil {
+			return err
+		}
+		return services.GrantFileToBuyers(tx, file)
+	})
+	if err != nil {
+		os.Remove(path)
+		c.JSON(http.StatusInternalServerError, utils.GenerateResponse("failed", "Failed to store file", nil, err.Error()))
+		return
+	}
+
+	c.JSON(http.StatusCreated, utils.GenerateResponse("success", "File uploaded successfully", file, ""))
+}
+
+// ListProductFiles lists the files attached to a digital product (admin only)
+// @Summary List product files
+// @Description List the downloadable files attached to a product.
+// @Tags Downloads
+// @Produce json
+// @Param id path int true "Product ID"
+// @Success 200 {object} utils.Response{data=[]models.ProductFile} "Files retrieved successfully"
+// @Failure 403 {object} utils.Response "Unauthorized action"
+// @Failure 500 {object} utils.Response "Failed to fetch files"
+// @Router /products/{id}/files [get]
+func ListProductFiles(c *gin.Context) {
+	if !utils.IsAdmin(c) {
+		c.JSON(http.StatusForbidden, utils.GenerateResponse("failed", "You are not authorized to perform this action", nil, ""))
+		return
+	}
+
+	var files []models.ProductFile
+	if err := database.DB.Where("product_id = ?", c.Param("id")).Order("id").Find(&files).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, utils.GenerateResponse("failed", "Failed to fetch files", nil, err.Error()))
+		return
+	}
+
+	c.JSON(http.StatusOK, utils.GenerateResponse("success", "Files retrieved successfully", files, ""))
+}
+
+// DeleteProductFile removes a file from a digital product (admin only)
+// @Summary Delete a product file
+// @Description Remove a file from a product and from storage. Existing download access to it is revoked.
+// @Tags Downloads
+// @Produce json
+// @Param id path int true "Product ID"
+// @Param file_id path int true "File ID"
+// @Success 200 {object} utils.Response "File deleted successfully"
+// @Failure 403 {object} utils.Response "Unauthorized action"
+// @Failure 404 {object} utils.Response "File not found"
+// @Failure 500 {object} utils.Response "Failed to delete file"
+// @Router /products/{id}/files/{file_id} [delete]
+func DeleteProductFile(c *gin.Context) {
+	if !utils.IsAdmin(c) {
+		c.JSON(http.StatusForbidden, utils.GenerateResponse("failed", "You are not authorized to perform this action", nil, ""))
+		return
+	}
+
+	var file models.ProductFile
+	if err := database.DB.Where("product_id = ?", c.Param("id")).First(&file, c.Param("file_id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, utils.GenerateResponse("failed", "File not found", nil, err.Error()))
+		return
+	}
+
+	if err := database.DB.Delete(&file).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, utils.GenerateResponse("failed", "Failed to delete file", nil, err.Error()))
+		return
+	}
+	os.Remove(file.StoragePath)
+
+	c.JSON(http.StatusOK, utils.GenerateResponse("success", "File deleted successfully", nil, ""))
+}
+
+// ListOrderDownloads issues signed download links for the digital products in an order
+// @Summary List order downloads
+// @Description Get short-lived signed download links for every file the order entitles you to, with the downloads remaining.
+// @Tags Downloads
+// @Produce json
+// @Param id path int true "Order ID"
+// @Success 200 {object} utils.Response{data=[]services.DownloadLink} "Downloads retrieved successfully"
+// @Failure 403 {object} utils.Response "Unauthorized action"
+// @Failure 404 {object} utils.Response "Order not found"
+// @Failure 500 {object} utils.Response "Failed to fetch downloads"
+// @Security ApiKeyAuth
+// @Router /orders/{id}/downloads [get]
+func ListOrderDownloads(c *gin.Context) {
+	var order models.Order
+	if err := database.DB.First(&order, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, utils.GenerateResponse("failed", "Order not found", nil, err.Error()))
+		return
+	}
+
+	if _, ok := orderActor(c, order); !ok {
+		c.JSON(http.StatusForbidden, utils.GenerateResponse("failed", "You are not authorized to view this order", nil, ""))
+		return
+	}
+
+	var grants []models.DownloadGrant
+	if err := database.DB.Preload("ProductFile").Where("order_id = ?", order.ID).Order("id").Find(&grants).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, utils.GenerateResponse("failed", "Failed to fetch downloads", nil, err.Error()))
+		return
+	}
+
+	links := make([]services.DownloadLink, len(grants))
+	for i, grant := range grants {
+		links[i] = services.SignDownloadLink(grant)
+	}
+
+	c.JSON(http.StatusOK, utils.GenerateResponse("success", "Downloads retrieved successfully", links, ""))
+}
+
+// Download serves a digital product file from a signed download link
+// @Summary Download a file
+// @Description Download a purchased file. The link must be signed and unexpired, and each download counts towards the purchase's download limit.
+// @Description Range requests resuming a download part-way through are not counted again.
+// @Tags Downloads
+// @Produce octet-stream
+// @Param token path string true "Download token"
+// @Param expires query int true "Link expiry as a Unix timestamp"
+// @Param signature query string true "Link signature"
+// @Success 200 {file} file "File contents"
+// @Failure 403 {object} utils.Response "Download link is invalid or has expired"
+// @Failure 404 {object} utils.Response "File is no longer available"
+// @Failure 410 {object} utils.Response "Download limit reached"
+// @Failure 500 {object} utils.Response "Failed to serve download"
+// @Router /downloads/{token} [get]
+func Download(c *gin.Context) {
+	file, content, err := services.ConsumeDownload(database.DB, c.Param("token"), c.Query("expires"), c.Query("signature"), resumesDownload(c.Request))
+	switch {
+	case errors.Is(err, services.ErrDownloadLinkInvalid):
+		c.JSON(http.StatusForbidden, utils.GenerateResponse("failed", "Download link is invalid or has expired", nil, err.Error()))
+		return
+	case errors.Is(err, services.ErrDownloadExhausted):
+		c.JSON(http.StatusGone, utils.GenerateResponse("failed", "Download limit reached", nil, err.Error()))
+		return
+	case errors.Is(err, services.ErrDownloadUnavailable):
+		c.JSON(http.StatusNotFound, utils.GenerateResponse("failed", "File is no longer available", nil, err.Error()))
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, utils.GenerateResponse("failed", "Failed to serve download", nil, err.Error()))
+		return
+	}
+	defer content.Close()
+
+	info, err := content.Stat()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, utils.GenerateResponse("failed", "Failed to serve download", nil, err.Error()))
+		return
+	}
+
+	if file.ContentType != "" {
+		c.Header("Content-Type", file.ContentType)
+	}
+	c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": file.FileName}))
+	http.ServeContent(c.Writer, c.Request, file.FileName, info.ModTime(), content)
+}
+
+// resumesDownload reports whether a request only asks for the rest of a file, i.e. it has a Range
+// not starting at the first byte. Full requests and ranges from byte 0 start a new download.
+func resumesDownload(r *http.Request) bool {
+	ranges := r.Header.Get("Range")
+	return strings.HasPrefix(ranges, "bytes=") && !strings.HasPrefix(ranges, "bytes=0-")
+}
@@ -0,0 +1,99 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+
+	"go-ecommerce-api/database"
+	"go-ecommerce-api/models"
+	"go-ecommerce-api/services"
+	"go-ecommerce-api/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// ListInventoryMovements queries the inventory ledger (admin only)
+// @Summary List inventory movements
+// @Description Get inventory ledger entries, newest first. Every stock change is recorded with its reason,
+// @Description quantity delta, actor and reference; summing the deltas of a product at a warehouse gives its stock.
+// @Tags Inventory
+// @Produce json
+// @Param product_id query int false "Only movements of this product"
+// @Param warehouse_id query int false "Only movements at this warehouse"
+// @Param order_id query int false "Only movements caused by this order"
+// @Param reason query string false "Only movements with this reason" Enums(sale, cancellation, adjustment, return, import)
+// @Param page query int false "Page number" default(1)
+// @Param per_page query int false "Entries per page, at most 200" default(50)
+// @Success 200 {object} utils.Response{data=object{movements=[]models.InventoryMovement,page=controllers.Page}} "Inventory movements retrieved successfully"
+// @Failure 400 {object} utils.Response "Invalid filter"
+// @Failure 403 {object} utils.Response "Unauthorized action"
+// @Failure 500 {object} utils.Response "Failed to fetch inventory movements"
+// @Router /inventory/movements [get]
+func ListInventoryMovements(c *gin.Context) {
+	if !utils.IsAdmin(c) {
+		c.JSON(http.StatusForbidden, utils.GenerateResponse("failed", "You are not authorized to perform this action", nil, ""))
+		return
+	}
+
+	var filter services.MovementFilter
+	for name, target := range map[string]*uint{"product_id": &filter.ProductID, "warehouse_id": &filter.WarehouseID, "order_id": &filter.OrderID} {
+		if value := c.Query(name); value != "" {
+			id, err := strconv.ParseUint(value, 10, 64)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, utils.GenerateResponse("failed", "Invalid filter", nil, name+" must be a positive integer"))
+				return
+			}
+			*target = uint(id)
+		}
+	}
+	filter.Reason = c.Query("reason")
+
+	page, ok := pageParams(c, 50)
+	if !ok {
+		return
+	}
+
+	// A new session lets the count and the page be built from the same filtered query
+	query := services.FilterMovements(database.DB.Model(&models.InventoryMovement{}), filter).Session(&gorm.Session{})
+	if err := query.Count(&page.Total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, utils.GenerateResponse("failed", "Failed to fetch inventory movements", nil, err.Error()))
+		return
+	}
+
+	var movements []models.InventoryMovement
+	if err := paginate(query.Order("id DESC"), page).Find(&movements).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, utils.GenerateResponse("failed", "Failed to fetch inventory movements", nil, err.Error()))
+		return
+	}
+
+	c.JSON(http.StatusOK, utils.GenerateResponse("success", "Inventory movements retrieved successfully", gin.H{
+		"movements": movements,
+		"page":      page,
+	}, ""))
+}
+
+// GetLowStockReport lists products below their reorder threshold (admin only)
+// @Summary Low-stock report
+// @Description Get every product whose stock is below its reorder threshold, furthest below first,
+// @Description with the units needed to get back to the threshold and when admins were last alerted.
+// @Tags Inventory
+// @Produce json
+// @Success 200 {object} utils.Response{data=[]services.LowStockItem} "Low-stock report retrieved successfully"
+// @Failure 403 {object} utils.Response "Unauthorized action"
+// @Failure 500 {object} utils.Response "Failed to build low-stock report"
+// @Router /inventory/low-stock [get]
+func GetLowStockReport(c *gin.Context) {
+	if !utils.IsAdmin(c) {
+		c.JSON(http.StatusForbidden, utils.GenerateResponse("failed", "You are not authorized to perform this action", nil, ""))
+		return
+	}
+
+	report, err := services.LowStockReport(database.DB)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, utils.GenerateResponse("failed", "Failed to build low-stock report", nil, err.Error()))
+		return
+	}
+
+	c.JSON(http.StatusOK, utils.GenerateResponse("success", "Low-stock report retrieved successfully", report, ""))
+}
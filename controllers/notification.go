@@ -0,0 +1,30 @@
+package controllers
+
+import (
+	"net/http"
+
+	"go-ecommerce-api/database"
+	"go-ecommerce-api/models"
+	"go-ecommerce-api/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ListNotifications lists the notifications sent or queued for the authenticated user
+// @Summary List notifications
+// @Description Fetch the authenticated user's 50 most recent notifications, such as back-in-stock alerts.
+// @Tags Notifications
+// @Produce json
+// @Success 200 {object} utils.Response{data=[]models.Notification} "Notifications retrieved successfully"
+// @Failure 500 {object} utils.Response "Failed to retrieve notifications"
+// @Security ApiKeyAuth
+// @Router /notifications [get]
+func ListNotifications(c *gin.Context) {
+	var notifications []models.Notification
+	if err := database.DB.Where("user_id = ?", c.MustGet("userID").(uint)).Order("created_at DESC").Limit(50).Find(&notifications).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, utils.GenerateResponse("failed", "Failed to retrieve notifications", nil, err.Error()))
+		return
+	}
+
+	c.JSON(http.StatusOK, utils.GenerateResponse("success", "Notifications retrieved successfully", notifications, ""))
+}
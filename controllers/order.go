@@ -1,85 +1,87 @@
 package controllers
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
+	"slices"
+	"strconv"
+	"strings"
 	"time"
 
 	"go-ecommerce-api/database"
 	"go-ecommerce-api/models"
+	"go-ecommerce-api/services"
 	"go-ecommerce-api/utils"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
 // PlaceOrder handles the creation of a new order
 
 // @Summary Place Order
 // @Description Create a new order with the provided order items and calculate the total amount based on the product prices.
+// @Description Stock is checked and reserved atomically; if any line cannot be covered nothing is ordered and every short line is reported.
+// @Description The reservation lasts until the order is paid; unpaid orders are cancelled when it expires.
+// @Description Bundles take their stock from their components, which are listed under the bundle line with their share of its price.
 // @Tags Orders
 // @Accept json
 // @Produce json
+// @Param Idempotency-Key header string false "Unique key for this checkout; retries with the same key return the original response instead of placing another order"
 // @Param order_items body []models.OrderItem true "OrderItem data"
 // @Success 201 {object} utils.Response{data=models.Order} "Order placed successfully"
 // @Failure 400 {object} utils.Response "Invalid input"
+// @Failure 404 {object} utils.Response "Product not found"
+// @Failure 409 {object} utils.Response{data=[]services.LineError} "Insufficient stock, or a request with the same Idempotency-Key is still being processed"
+// @Failure 422 {object} utils.Response "Idempotency-Key was already used for a different request"
 // @Failure 500 {object} utils.Response "Failed to place order"
 // @Security ApiKeyAuth
 // @Router /orders [post]
 func PlaceOrder(c *gin.Context) {
 	var orderInput struct {
 		OrderItems []struct {
-			ProductID uint `json:"product_id"`
-			Quantity  int  `json:"quantity"`
-		} `json:"order_items"`
+			ProductID uint `json:"product_id" binding:"required"`
+			Quantity  int  `json:"quantity" binding:"required,gte=1"`
+		} `json:"order_items" binding:"required,min=1,dive"`
 	}
 
 	if err := c.ShouldBindJSON(&orderInput); err != nil {
-		c.JSON(http.StatusBadRequest, utils.GenerateResponse("failed", "Invalid input", nil, err.Error()))
+		c.JSON(http.StatusBadRequest, utils.GenerateResponse("failed", "Invalid input", utils.FieldErrors(err), err.Error()))
 		return
 	}
 
-	userID := c.MustGet("userID").(uint)
-	var totalAmount float64
-	orderItems := []models.OrderItem{}
+	lines := make([]services.OrderLine, len(orderInput.OrderItems))
+	for i, item := range orderInput.OrderItems {
+		lines[i] = services.OrderLine{ProductID: item.ProductID, Quantity: item.Quantity}
+	}
 
-	for _, item := range orderInput.OrderItems {
-		var product models.Product
-		if err := database.DB.First(&product, item.ProductID).Error; err != nil {
+	order, err := services.PlaceOrder(c.MustGet("userID").(uint), lines)
+	if err != nil {
+		var stockErr *services.StockError
+		switch {
+		case errors.As(err, &stockErr):
+			c.JSON(http.StatusConflict, utils.GenerateResponse("failed", "Insufficient stock", stockErr.Lines, err.Error()))
+		case errors.Is(err, services.ErrProductNotFound):
 			c.JSON(http.StatusNotFound, utils.GenerateResponse("failed", "Product not found", nil, err.Error()))
-			return
-		}
-
-		orderItem := models.OrderItem{
-			ProductID: item.ProductID,
-			Quantity:  item.Quantity,
-			Price:     product.Price,
+		default:
+			c.JSON(http.StatusInternalServerError, utils.GenerateResponse("failed", "Failed to place order", nil, err.Error()))
 		}
-		totalAmount += float64(item.Quantity) * product.Price
-		orderItems = append(orderItems, orderItem)
-	}
-
-	order := models.Order{
-		UserID:      userID,
-		Status:      "Pending",
-		TotalAmount: totalAmount,
-		CreatedAt:   time.Now(),
-		OrderItems:  orderItems,
-	}
-
-	if err := database.DB.Create(&order).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, utils.GenerateResponse("failed", "Failed to place order", nil, err.Error()))
 		return
 	}
 
+	c.Header("ETag", utils.ETag(order.ID, order.Version))
 	c.JSON(http.StatusCreated, utils.GenerateResponse("success", "Order placed successfully", order, ""))
 }
 
 // ListUserOrders retrieves all orders for the authenticated user
 
 // @Summary List User Orders
-// @Description Fetch all orders for the currently authenticated user with order details.
+// @Description Fetch all orders for the currently authenticated user with order details and the history of each order's status.
 // @Tags Orders
 // @Produce json
 // @Success 200 {object} utils.Response{data=[]models.Order} "Orders retrieved successfully"
+// @Success 304 "Not modified"
 // @Failure 500 {object} utils.Response "Failed to retrieve orders"
 // @Security ApiKeyAuth
 // @Router /orders [get]
@@ -87,28 +89,210 @@ func ListUserOrders(c *gin.Context) {
 	userID := c.MustGet("userID").(uint)
 	var orders []models.Order
 
-	if err := database.DB.Preload("OrderItems").Where("user_id = ?", userID).Find(&orders).Error; err != nil {
+	if err := database.DB.Preload("OrderItems", "bundle_item_id IS NULL").Preload("OrderItems.Components").Preload("Events", func(tx *gorm.DB) *gorm.DB { return tx.Order("id") }).
+		Where("user_id = ?", userID).Order("id").Find(&orders).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, utils.GenerateResponse("failed", "Failed to retrieve orders", nil, err.Error()))
 		return
 	}
 
+	tags := make([]string, len(orders))
+	for i, order := range orders {
+		tags[i] = fmt.Sprintf("%d-%d", order.ID, order.Version)
+	}
+	if notModified(c, utils.CollectionETag(tags)) {
+		return
+	}
+
 	c.JSON(http.StatusOK, utils.GenerateResponse("success", "Orders retrieved successfully", orders, ""))
 }
 
-// CancelOrder allows a user to cancel an order in the Pending status
+// SearchOrders lists every customer's orders with filters (admin only)
+
+// @Summary Search Orders
+// @Description Search all orders, with filters, sorting and pagination, and a summary of the matching orders.
+// @Description Dates are RFC 3339 timestamps or plain dates; a plain to date includes that whole day.
+// @Description Revenue in the summary only counts paid orders that were not cancelled or refunded.
+// @Tags Orders
+// @Produce json
+// @Param status query string false "Comma-separated statuses, e.g. paid,processing"
+// @Param from query string false "Placed on or after this time"
+// @Param to query string false "Placed before this time, or on or before this date"
+// @Param user_id query int false "Only orders of this user"
+// @Param email query string false "Only orders of users whose email contains this"
+// @Param min_total query number false "Minimum order total"
+// @Param max_total query number false "Maximum order total"
+// @Param product_id query int false "Only orders containing this product, on its own or in a bundle"
+// @Param sort query string false "Sort key, prefixed with - for descending" Enums(id, -id, created_at, -created_at, total_amount, -total_amount, status, -status) default(-created_at)
+// @Param page query int false "Page number" default(1)
+// @Param per_page query int false "Orders per page, at most 200" default(50)
+// @Success 200 {object} utils.Response{data=object{orders=[]models.Order,page=controllers.Page,summary=services.OrderSummary}} "Orders retrieved successfully"
+// @Failure 400 {object} utils.Response "Invalid filter"
+// @Failure 403 {object} utils.Response "Unauthorized action"
+// @Failure 500 {object} utils.Response "Failed to retrieve orders"
+// @Security ApiKeyAuth
+// @Router /orders/search [get]
+func SearchOrders(c *gin.Context) {
+	if !utils.IsAdmin(c) {
+		c.JSON(http.StatusForbidden, utils.GenerateResponse("failed", "You are not authorized to perform this action", nil, ""))
+		return
+	}
+
+	filter, err := orderFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, utils.GenerateResponse("failed", "Invalid filter", nil, err.Error()))
+		return
+	}
+
+	sort := c.DefaultQuery("sort", "-created_at")
+	column, ok := services.OrderSorts[strings.TrimPrefix(sort, "-")]
+	if !ok {
+		c.JSON(http.StatusBadRequest, utils.GenerateResponse("failed", "Invalid filter", nil, "unknown sort key "+strconv.Quote(sort)))
+		return
+	}
+	if strings.HasPrefix(sort, "-") {
+		column += " DESC"
+	}
+
+	page, ok := pageParams(c, 50)
+	if !ok {
+		return
+	}
+
+	// A new session lets the summary and the page be built from the same filtered query
+	query := services.FilterOrders(database.DB.Model(&models.Order{}), filter).Session(&gorm.Session{})
+	summary, err := services.SummarizeOrders(query)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, utils.GenerateResponse("failed", "Failed to retrieve orders", nil, err.Error()))
+		return
+	}
+	page.Total = summary.Orders
+
+	var orders []models.Order
+	err = paginate(query.Preload("OrderItems", "bundle_item_id IS NULL").Preload("OrderItems.Components").Order(column+", orders.id"), page).
+		Find(&orders).Error
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, utils.GenerateResponse("failed", "Failed to retrieve orders", nil, err.Error()))
+		return
+	}
+
+	c.JSON(http.StatusOK, utils.GenerateResponse("success", "Orders retrieved successfully", gin.H{
+		"orders":  orders,
+		"page":    page,
+		"summary": summary,
+	}, ""))
+}
+
+// orderFilter reads the order search filters from the query string
+func orderFilter(c *gin.Context) (services.OrderFilter, error) {
+	filter := services.OrderFilter{Email: strings.TrimSpace(c.Query("email"))}
+
+	if statuses := c.Query("status"); statuses != "" {
+		for _, status := range strings.Split(statuses, ",") {
+			status = strings.ToLower(strings.TrimSpace(status))
+			if !slices.Contains(services.OrderStatuses, status) {
+				return filter, fmt.Errorf("unknown status %q", status)
+			}
+			filter.Statuses = append(filter.Statuses, status)
+		}
+	}
+
+	for name, target := range map[string]**time.Time{"from": &filter.From, "to": &filter.To} {
+		value := c.Query(name)
+		if value == "" {
+			continue
+		}
+		at, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			if at, err = time.Parse(time.DateOnly, value); err != nil {
+				return filter, fmt.Errorf("%s must be an RFC 3339 time or a date", name)
+			}
+			// A plain to date covers the whole of that day
+			if name == "to" {
+				at = at.AddDate(0, 0, 1)
+			}
+		}
+		*target = &at
+	}
+
+	for name, target := range map[string]*uint{"user_id": &filter.UserID, "product_id": &filter.ProductID} {
+		if value := c.Query(name); value != "" {
+			id, err := strconv.ParseUint(value, 10, 64)
+			if err != nil {
+				return filter, fmt.Errorf("%s must be a positive integer", name)
+			}
+			*target = uint(id)
+		}
+	}
+
+	for name, target := range map[string]**float64{"min_total": &filter.MinTotal, "max_total": &filter.MaxTotal} {
+		if value := c.Query(name); value != "" {
+			total, err := strconv.ParseFloat(value, 64)
+			if err != nil || total < 0 {
+				return filter, fmt.Errorf("%s must be a non-negative number", name)
+			}
+			*target = &total
+		}
+	}
+
+	return filter, nil
+}
+
+// GetOrder retrieves one order for its owner or an admin
+
+// @Summary Get Order
+// @Description Fetch one order with its items and the history of its status. Only the owner of the order or an admin can view it.
+// @Description Each item carries the product's name, SKU and attributes as they were when the order was placed.
+// @Description The response carries an ETag; send it back in If-None-Match for a conditional GET.
+// @Tags Orders
+// @Produce json
+// @Param id path int true "Order ID"
+// @Param If-None-Match header string false "ETag from a previous response"
+// @Success 200 {object} utils.Response{data=models.Order} "Order retrieved successfully"
+// @Success 304 "Not modified"
+// @Failure 403 {object} utils.Response "Unauthorized action"
+// @Failure 404 {object} utils.Response "Order not found"
+// @Security ApiKeyAuth
+// @Router /orders/{id} [get]
+func GetOrder(c *gin.Context) {
+	var order models.Order
+	err := database.DB.Preload("OrderItems", "bundle_item_id IS NULL").Preload("OrderItems.Components").
+		Preload("Events", func(tx *gorm.DB) *gorm.DB { return tx.Order("id") }).
+		First(&order, c.Param("id")).Error
+	if err != nil {
+		c.JSON(http.StatusNotFound, utils.GenerateResponse("failed", "Order not found", nil, err.Error()))
+		return
+	}
+
+	if _, ok := orderActor(c, order); !ok {
+		c.JSON(http.StatusForbidden, utils.GenerateResponse("failed", "You are not authorized to view this order", nil, ""))
+		return
+	}
+
+	if notModified(c, utils.ETag(order.ID, order.Version)) {
+		return
+	}
+
+	c.JSON(http.StatusOK, utils.GenerateResponse("success", "Order retrieved successfully", order, ""))
+}
+
+// CancelOrder allows a user to cancel an order in the pending status
 
 // @Summary Cancel Order
-// @Description Cancel an order if it is in the pending status. Only the owner of the order can cancel it.
+// @Description Cancel an order if it is in the pending status. Only the owner of the order or an admin can cancel it,
+// @Description and admins can also cancel paid orders that have not shipped yet.
+// @Description Stock reserved for the order is released immediately, and stock already sold is restocked.
 // @Tags Orders
 // @Produce json
 // @Param id path int true "Order ID"
+// @Param cancel body object{note=string} false "Optional reason, recorded in the order's history"
 // @Success 200 {object} utils.Response{data=models.Order} "Order cancelled successfully"
-// @Failure 400 {object} utils.Response "Only pending orders can be cancelled"
+// @Failure 400 {object} utils.Response{data=[]utils.FieldError} "Invalid input"
 // @Failure 404 {object} utils.Response "Order not found"
 // @Failure 403 {object} utils.Response "Unauthorized action"
+// @Failure 409 {object} utils.Response "Order can no longer be cancelled, or was modified by another request"
 // @Failure 500 {object} utils.Response "Failed to cancel order"
 // @Security ApiKeyAuth
-// @Router /orders/{id} [delete]
+// @Router /orders/{id}/cancel [put]
 func CancelOrder(c *gin.Context) {
 	orderID := c.Param("id")
 	var order models.Order
@@ -118,54 +302,65 @@ func CancelOrder(c *gin.Context) {
 		return
 	}
 
-	if order.UserID != c.MustGet("userID").(uint) {
+	role, ok := orderActor(c, order)
+	if !ok {
 		c.JSON(http.StatusForbidden, utils.GenerateResponse("failed", "You are not authorized to cancel this order", nil, ""))
 		return
 	}
 
-	if order.Status != "Pending" {
-		c.JSON(http.StatusBadRequest, utils.GenerateResponse("failed", "Only pending orders can be cancelled", nil, ""))
-		return
+	// The reason for cancelling is optional, so an empty body is fine
+	var input struct {
+		Note string `json:"note" binding:"max=500"`
+	}
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, utils.GenerateResponse("failed", "Invalid input", utils.FieldErrors(err), err.Error()))
+			return
+		}
 	}
 
-	order.Status = "Cancelled"
-	if err := database.DB.Save(&order).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, utils.GenerateResponse("failed", "Failed to cancel order", nil, err.Error()))
+	if !transitionOrder(c, &order, services.OrderCancelled, role, input.Note) {
 		return
 	}
 
+	c.Header("ETag", utils.ETag(order.ID, order.Version))
+
 	c.JSON(http.StatusOK, utils.GenerateResponse("success", "Order cancelled successfully", order, ""))
 }
 
-// UpdateOrderStatus allows an admin to update the status of an order
+// UpdateOrderStatus moves an order to its next status
 
 // @Summary Update Order Status
-// @Description Admin can update the status of an order (e.g., to shipped, delivered, etc.).
+// @Description Move an order along its lifecycle: pending, paid, processing, shipped, delivered and completed, or cancelled and refunded.
+// @Description Only the moves in the order state machine are allowed, and each is limited to certain roles:
+// @Description admins drive fulfillment, while customers may only cancel their pending orders and mark delivered ones completed.
+// @Description Paying turns the stock reservation into a sale and unlocks downloads; cancelling restocks the order, and refunding restocks it too, as a return once it was delivered.
+// @Description The If-Match header must carry the order's current ETag; stale writes are rejected.
 // @Tags Orders
 // @Accept json
 // @Produce json
 // @Param id path int true "Order ID"
-// @Param status body string true "New status of the order" Enums(pending, shipped, delivered, cancelled)
+// @Param If-Match header string true "Current ETag of the order"
+// @Param status body object{status=string,note=string} true "New status of the order and an optional note for its history"
 // @Success 200 {object} utils.Response{data=models.Order} "Order status updated successfully"
-// @Failure 400 {object} utils.Response "Invalid input"
+// @Failure 400 {object} utils.Response{data=[]utils.FieldError} "Invalid input"
 // @Failure 404 {object} utils.Response "Order not found"
 // @Failure 403 {object} utils.Response "Unauthorized action"
+// @Failure 409 {object} utils.Response "Invalid status transition"
+// @Failure 412 {object} utils.Response "Order was modified by another request"
+// @Failure 428 {object} utils.Response "If-Match header is required"
 // @Failure 500 {object} utils.Response "Failed to update order status"
 // @Security ApiKeyAuth
-// @Router /orders/{id}/status [put]
+// @Router /orders/{id} [put]
 func UpdateOrderStatus(c *gin.Context) {
-	if !utils.IsAdmin(c) {
-		c.JSON(http.StatusForbidden, utils.GenerateResponse("failed", "You are not authorized to perform this action", nil, ""))
-		return
-	}
-
 	orderID := c.Param("id")
 	var input struct {
-		Status string `json:"status" binding:"required"`
+		Status string `json:"status" binding:"required,oneof=pending paid processing shipped delivered completed cancelled refunded"`
+		Note   string `json:"note" binding:"max=500"`
 	}
 
 	if err := c.ShouldBindJSON(&input); err != nil {
-		c.JSON(http.StatusBadRequest, utils.GenerateResponse("failed", "Invalid input", nil, err.Error()))
+		c.JSON(http.StatusBadRequest, utils.GenerateResponse("failed", "Invalid input", utils.FieldErrors(err), err.Error()))
 		return
 	}
 
@@ -175,11 +370,72 @@ func UpdateOrderStatus(c *gin.Context) {
 		return
 	}
 
-	order.Status = input.Status
-	if err := database.DB.Save(&order).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, utils.GenerateResponse("failed", "Failed to update order status", nil, err.Error()))
+	role, ok := orderActor(c, order)
+	if !ok {
+		c.JSON(http.StatusForbidden, utils.GenerateResponse("failed", "You are not authorized to perform this action", nil, ""))
+		return
+	}
+
+	if !checkIfMatch(c, utils.ETag(order.ID, order.Version)) {
+		return
+	}
+
+	if !transitionOrder(c, &order, input.Status, role, input.Note) {
 		return
 	}
 
+	c.Header("ETag", utils.ETag(order.ID, order.Version))
+
 	c.JSON(http.StatusOK, utils.GenerateResponse("success", "Order status updated successfully", order, ""))
 }
+
+// orderActor returns the role the caller acts in on an order: admin, or customer for its owner
+func orderActor(c *gin.Context, order models.Order) (string, bool) {
+	if utils.IsAdmin(c) {
+		return services.OrderActorAdmin, true
+	}
+	if order.UserID == c.MustGet("userID").(uint) {
+		return services.OrderActorCustomer, true
+	}
+	return "", false
+}
+
+// transitionOrder moves order to status through the order state machine, recording the move in its
+// history and running the side effects, and responds with the matching error if the move is not
+// allowed or fails
+func transitionOrder(c *gin.Context, order *models.Order, status, role, note string) bool {
+	from := order.Status
+	if err := services.CheckOrderTransition(from, status, role); err != nil {
+		if errors.Is(err, services.ErrTransitionForbidden) {
+			c.JSON(http.StatusForbidden, utils.GenerateResponse("failed", "You are not authorized to perform this action", nil, err.Error()))
+			return false
+		}
+		c.JSON(http.StatusConflict, utils.GenerateResponse("failed", "Invalid status transition", nil, err.Error()))
+		return false
+	}
+
+	actorID := c.MustGet("userID").(uint)
+	order.Status = status
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := saveVersioned(tx, order, &order.Version); err != nil {
+			return err
+		}
+		change := services.OrderStatusChange{From: from, Role: role, ActorID: &actorID, Note: note}
+		return services.SettleOrderStatus(tx, *order, change)
+	})
+	if err != nil {
+		order.Status = from
+		if errors.Is(err, errVersionConflict) {
+			// Only status updates carry an ETag; a cancel without one simply raced another change
+			status := http.StatusConflict
+			if c.GetHeader("If-Match") != "" {
+				status = http.StatusPreconditionFailed
+			}
+			c.JSON(status, utils.GenerateResponse("failed", "Order has been modified by another request", nil, err.Error()))
+			return false
+		}
+		c.JSON(http.StatusInternalServerError, utils.GenerateResponse("failed", "Failed to update order status", nil, err.Error()))
+		return false
+	}
+	return true
+}
@@ -0,0 +1,43 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+
+	"go-ecommerce-api/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// maxPerPage caps the page size of paginated listings
+const maxPerPage = 200
+
+// Page is the pagination metadata returned alongside a page of results
+type Page struct {
+	Page    int   `json:"page"`
+	PerPage int   `json:"per_page"`
+	Total   int64 `json:"total"`
+}
+
+// pageParams reads the page and per_page query parameters, responding with 400 if they are invalid
+func pageParams(c *gin.Context, defaultPerPage int) (Page, bool) {
+	page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if err != nil || page < 1 {
+		c.JSON(http.StatusBadRequest, utils.GenerateResponse("failed", "Invalid page", nil, "page must be a positive integer"))
+		return Page{}, false
+	}
+
+	perPage, err := strconv.Atoi(c.DefaultQuery("per_page", strconv.Itoa(defaultPerPage)))
+	if err != nil || perPage < 1 || perPage > maxPerPage {
+		c.JSON(http.StatusBadRequest, utils.GenerateResponse("failed", "Invalid page size", nil, "per_page must be between 1 and "+strconv.Itoa(maxPerPage)))
+		return Page{}, false
+	}
+
+	return Page{Page: page, PerPage: perPage}, true
+}
+
+// paginate limits a query to the requested page
+func paginate(query *gorm.DB, page Page) *gorm.DB {
+	return query.Offset((page.Page - 1) * page.PerPage).Limit(page.PerPage)
+}
@@ -0,0 +1,186 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"go-ecommerce-api/database"
+	"go-ecommerce-api/models"
+	"go-ecommerce-api/services"
+	"go-ecommerce-api/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetPriceHistory lists every recorded price change of a product (admin only)
+// @Summary Get product price history
+// @Description List a product's price changes, newest first, with who made each change and when.
+// @Tags Prices
+// @Produce json
+// @Param id path int true "Product ID"
+// @Success 200 {object} utils.Response{data=[]models.PriceChange} "Price history retrieved successfully"
+// @Failure 403 {object} utils.Response "Unauthorized action"
+// @Failure 404 {object} utils.Response "Product not found"
+// @Failure 500 {object} utils.Response "Failed to fetch price history"
+// @Router /products/{id}/prices [get]
+func GetPriceHistory(c *gin.Context) {
+	if !utils.IsAdmin(c) {
+		c.JSON(http.StatusForbidden, utils.GenerateResponse("failed", "You are not authorized to perform this action", nil, ""))
+		return
+	}
+
+	var product models.Product
+	if err := database.DB.First(&product, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, utils.GenerateResponse("failed", "Product not found", nil, err.Error()))
+		return
+	}
+
+	var history []models.PriceChange
+	if err := database.DB.Where("product_id = ?", product.ID).Order("created_at DESC, id DESC").Find(&history).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, utils.GenerateResponse("failed", "Failed to fetch price history", nil, err.Error()))
+		return
+	}
+
+	c.JSON(http.StatusOK, utils.GenerateResponse("success", "Price history retrieved successfully", history, ""))
+}
+
+// CreatePriceSchedule schedules a future price for a product (admin only)
+// @Summary Schedule a price change
+// @Description Schedule a price to take effect at starts_at. When ends_at is set the previous price is restored at that time, e.g. for a sale.
+// @Tags Prices
+// @Accept json
+// @Produce json
+// @Param id path int true "Product ID"
+// @Param schedule body object{price=number,starts_at=string,ends_at=string} true "Scheduled price"
+// @Success 201 {object} utils.Response{data=models.PriceSchedule} "Price change scheduled successfully"
+// @Failure 400 {object} utils.Response{data=[]utils.FieldError} "Invalid request data"
+// @Failure 403 {object} utils.Response "Unauthorized action"
+// @Failure 404 {object} utils.Response "Product not found"
+// @Failure 409 {object} utils.Response "Schedule overlaps an existing schedule"
+// @Failure 500 {object} utils.Response "Failed to schedule price change"
+// @Router /products/{id}/price-schedules [post]
+func CreatePriceSchedule(c *gin.Context) {
+	if !utils.IsAdmin(c) {
+		c.JSON(http.StatusForbidden, utils.GenerateResponse("failed", "You are not authorized to perform this action", nil, ""))
+		return
+	}
+
+	var input struct {
+		Price    *float64   `json:"price" binding:"required,gte=0,price"`
+		StartsAt time.Time  `json:"starts_at" binding:"required"`
+		EndsAt   *time.Time `json:"ends_at"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, utils.GenerateResponse("failed", "Invalid request data", utils.FieldErrors(err), err.Error()))
+		return
+	}
+
+	if input.EndsAt != nil && !input.EndsAt.After(input.StartsAt) {
+		c.JSON(http.StatusBadRequest, utils.GenerateResponse("failed", "Invalid request data", nil, "ends_at must be after starts_at"))
+		return
+	}
+	if input.EndsAt != nil && input.EndsAt.Before(time.Now()) {
+		c.JSON(http.StatusBadRequest, utils.GenerateResponse("failed", "Invalid request data", nil, "ends_at must be in the future"))
+		return
+	}
+
+	var product models.Product
+	if err := database.DB.First(&product, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, utils.GenerateResponse("failed", "Product not found", nil, err.Error()))
+		return
+	}
+
+	// Two schedules running at once would each try to restore the other's price
+	overlap := database.DB.Model(&models.PriceSchedule{}).
+		Where("product_id = ? AND status IN ?", product.ID, []string{"scheduled", "active"}).
+		Where("ends_at IS NULL OR ends_at > ?", input.StartsAt)
+	if input.EndsAt != nil {
+		overlap = overlap.Where("starts_at < ?", *input.EndsAt)
+	}
+	var overlapping int64
+	if err := overlap.Count(&overlapping).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, utils.GenerateResponse("failed", "Failed to schedule price change", nil, err.Error()))
+		return
+	}
+	if overlapping > 0 {
+		c.JSON(http.StatusConflict, utils.GenerateResponse("failed", "Schedule overlaps an existing schedule", nil, ""))
+		return
+	}
+
+	schedule := models.PriceSchedule{
+		ProductID: product.ID,
+		Price:     *input.Price,
+		StartsAt:  input.StartsAt,
+		EndsAt:    input.EndsAt,
+		Status:    "scheduled",
+		CreatedBy: c.MustGet("userID").(uint),
+	}
+	if err := database.DB.Create(&schedule).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, utils.GenerateResponse("failed", "Failed to schedule price change", nil, err.Error()))
+		return
+	}
+
+	c.JSON(http.StatusCreated, utils.GenerateResponse("success", "Price change scheduled successfully", schedule, ""))
+}
+
+// ListPriceSchedules lists the price schedules of a product (admin only)
+// @Summary List price schedules
+// @Description List all price schedules of a product, ordered by start time.
+// @Tags Prices
+// @Produce json
+// @Param id path int true "Product ID"
+// @Success 200 {object} utils.Response{data=[]models.PriceSchedule} "Price schedules retrieved successfully"
+// @Failure 403 {object} utils.Response "Unauthorized action"
+// @Failure 500 {object} utils.Response "Failed to fetch price schedules"
+// @Router /products/{id}/price-schedules [get]
+func ListPriceSchedules(c *gin.Context) {
+	if !utils.IsAdmin(c) {
+		c.JSON(http.StatusForbidden, utils.GenerateResponse("failed", "You are not authorized to perform this action", nil, ""))
+		return
+	}
+
+	var schedules []models.PriceSchedule
+	if err := database.DB.Where("product_id = ?", c.Param("id")).Order("starts_at").Find(&schedules).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, utils.GenerateResponse("failed", "Failed to fetch price schedules", nil, err.Error()))
+		return
+	}
+
+	c.JSON(http.StatusOK, utils.GenerateResponse("success", "Price schedules retrieved successfully", schedules, ""))
+}
+
+// CancelPriceSchedule cancels a pending price schedule or ends an active one early (admin only)
+// @Summary Cancel a price schedule
+// @Description Cancel a schedule that has not started yet, or end an active one now and restore the previous price.
+// @Tags Prices
+// @Produce json
+// @Param id path int true "Price schedule ID"
+// @Success 200 {object} utils.Response{data=models.PriceSchedule} "Price schedule cancelled successfully"
+// @Failure 400 {object} utils.Response "Price schedule has already finished"
+// @Failure 403 {object} utils.Response "Unauthorized action"
+// @Failure 404 {object} utils.Response "Price schedule not found"
+// @Failure 500 {object} utils.Response "Failed to cancel price schedule"
+// @Router /price-schedules/{id} [delete]
+func CancelPriceSchedule(c *gin.Context) {
+	if !utils.IsAdmin(c) {
+		c.JSON(http.StatusForbidden, utils.GenerateResponse("failed", "You are not authorized to perform this action", nil, ""))
+		return
+	}
+
+	var schedule models.PriceSchedule
+	if err := database.DB.First(&schedule, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, utils.GenerateResponse("failed", "Price schedule not found", nil, err.Error()))
+		return
+	}
+
+	if err := services.CancelPriceSchedule(&schedule); err != nil {
+		if errors.Is(err, services.ErrScheduleNotCancellable) {
+			c.JSON(http.StatusBadRequest, utils.GenerateResponse("failed", "Price schedule has already finished", nil, err.Error()))
+			return
+		}
+		c.JSON(http.StatusInternalServerError, utils.GenerateResponse("failed", "Failed to cancel price schedule", nil, err.Error()))
+		return
+	}
+
+	c.JSON(http.StatusOK, utils.GenerateResponse("success", "Price schedule cancelled successfully", schedule, ""))
+}
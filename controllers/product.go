@@ -1,71 +1,229 @@
 package controllers
 
 import (
+	"encoding/json"
+	"errors"
 	"net/http"
+	"time"
 
 	"go-ecommerce-api/database"
 	"go-ecommerce-api/models"
+	"go-ecommerce-api/services"
 	"go-ecommerce-api/utils"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
 // CreateProduct handles creating a new product (admin only)
 // @Summary Create a new product
 // @Description Admins can create a new product by providing necessary details
+// @Description New products start as drafts unless a status is given; only published products are listed and sold.
+// @Description Scheduled products with the preorder backorder policy are also listed and sold before they launch.
 // @Tags Products
 // @Accept json
 // @Produce json
-// @Param product body models.Product true "Product data"
-// @Success 201 {object} utils.Response "Product created successfully"
-// @Failure 400 {object} utils.Response "Invalid request data"
+// @Param product body models.ProductInput true "Product data"
+// @Success 201 {object} utils.Response{data=models.Product} "Product created successfully"
+// @Failure 400 {object} utils.Response{data=[]utils.FieldError} "Invalid request data"
+// @Failure 403 {object} utils.Response "Unauthorized action"
 // @Failure 500 {object} utils.Response "Failed to create product"
 // @Router /products [post]
 func CreateProduct(c *gin.Context) {
-	var input models.Product
-	if err := c.ShouldBindJSON(&input); err != nil {
-		c.JSON(http.StatusBadRequest, utils.GenerateResponse("failed", "Invalid request data", nil, err.Error()))
+	if !utils.IsAdmin(c) {
+		c.JSON(http.StatusForbidden, utils.GenerateResponse("failed", "You are not authorized to perform this action", nil, ""))
 		return
 	}
 
-	if err := database.DB.Create(&input).Error; err != nil {
+	var input models.ProductInput
+	if !bindProductInput(c, &input, true) {
+		return
+	}
+
+	adminID := c.MustGet("userID").(uint)
+	product := models.Product{
+		SKU:              input.SKU,
+		Name:             input.Name,
+		Description:      input.Description,
+		Price:            *input.Price,
+		Stock:            *input.Stock,
+		ReorderThreshold: input.ReorderThreshold,
+		BackorderPolicy:  services.BackorderNone,
+		BackorderLimit:   input.BackorderLimit,
+		ExpectedAt:       input.ExpectedAt,
+		Status:           services.ProductDraft,
+		IsDigital:        input.IsDigital,
+		Attributes:       input.Attributes,
+	}
+	if input.BackorderPolicy != "" {
+		product.BackorderPolicy = input.BackorderPolicy
+	}
+	if !applyLifecycle(c, &product, input) {
+		return
+	}
+	// Leaving the download limit out falls back to the column default
+	if input.DownloadLimit > 0 {
+		product.DownloadLimit = input.DownloadLimit
+	}
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := services.AssignSlug(tx, &product); err != nil {
+			return err
+		}
+		if err := tx.Create(&product).Error; err != nil {
+			return err
+		}
+		change := services.StockChange{Reason: services.MovementAdjustment, ActorID: &adminID, Note: "initial stock"}
+		return services.SyncDefaultStockLevel(tx, product, 0, product.Stock, change)
+	})
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, utils.GenerateResponse("failed", "Failed to create product", nil, err.Error()))
 		return
 	}
 
-	c.JSON(http.StatusCreated, utils.GenerateResponse("success", "Product created successfully", input, ""))
+	c.Header("ETag", utils.ETag(product.ID, product.Version))
+	c.JSON(http.StatusCreated, utils.GenerateResponse("success", "Product created successfully", product, ""))
 }
 
 // GetProducts handles retrieving all products
 // @Summary Retrieve all products
-// @Description Get a list of all products
+// @Description Get a list of all products, optionally filtered by custom attributes,
+// @Description e.g. attr[material]=steel, attr_min[weight]=1 or attr_max[weight]=5.
+// @Description available is the unreserved stock across all warehouses.
 // @Tags Products
 // @Produce json
+// @Param status query string false "Publishing status to list (admin only; others always see published products)" Enums(draft, scheduled, published, unpublished)
+// @Param attr query object false "Exact attribute matches, as attr[key]=value"
+// @Param attr_min query object false "Lower bounds for number attributes, as attr_min[key]=value"
+// @Param attr_max query object false "Upper bounds for number attributes, as attr_max[key]=value"
+// @Param locale query string false "Locale for name and description, e.g. pt-BR; overrides Accept-Language"
+// @Param Accept-Language header string false "Preferred locales; untranslated content falls back to the default locale"
 // @Success 200 {object} utils.Response "Products retrieved successfully"
+// @Success 304 "Not modified"
+// @Failure 400 {object} utils.Response "Invalid attribute filter"
 // @Failure 500 {object} utils.Response "Failed to fetch products"
 // @Router /products [get]
 func GetProducts(c *gin.Context) {
+	base := visibleProducts(c).Order("id")
+	if status := c.Query("status"); status != "" && utils.IsAdmin(c) {
+		base = base.Where("status = ?", status)
+	}
+
+	query, err := services.FilterByAttributes(database.DB, base, c.QueryMap("attr"), c.QueryMap("attr_min"), c.QueryMap("attr_max"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, utils.GenerateResponse("failed", "Invalid attribute filter", nil, err.Error()))
+		return
+	}
+
 	var products []models.Product
-	if err := database.DB.Find(&products).Error; err != nil {
+	if err := query.Find(&products).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, utils.GenerateResponse("failed", "Failed to fetch products", nil, err.Error()))
 		return
 	}
 
+	if err := presentProducts(products, requestLocales(c)); err != nil {
+		c.JSON(http.StatusInternalServerError, utils.GenerateResponse("failed", "Failed to fetch products", nil, err.Error()))
+		return
+	}
+
+	tags := make([]string, len(products))
+	for i, product := range products {
+		if tags[i], err = presentedETag(product); err != nil {
+			c.JSON(http.StatusInternalServerError, utils.GenerateResponse("failed", "Failed to fetch products", nil, err.Error()))
+			return
+		}
+	}
+	if notModified(c, utils.CollectionETag(tags)) {
+		return
+	}
+
 	c.JSON(http.StatusOK, utils.GenerateResponse("success", "Products retrieved successfully", products, ""))
 }
 
+// GetProduct handles retrieving a single product
+// @Summary Retrieve a product
+// @Description Get a product by ID. The response carries an ETag; send it back in If-None-Match for a conditional GET.
+// @Description Bundles include their components, and available reflects the components' stock.
+// @Tags Products
+// @Produce json
+// @Param id path string true "Product ID"
+// @Param If-None-Match header string false "ETag from a previous response"
+// @Param locale query string false "Locale for name and description, e.g. pt-BR; overrides Accept-Language"
+// @Param Accept-Language header string false "Preferred locales; untranslated content falls back to the default locale"
+// @Success 200 {object} utils.Response{data=models.Product} "Product retrieved successfully"
+// @Success 304 "Not modified"
+// @Failure 404 {object} utils.Response "Product not found"
+// @Router /products/{id} [get]
+func GetProduct(c *gin.Context) {
+	var product models.Product
+	if err := visibleProducts(c).Preload("Components.Component").First(&product, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, utils.GenerateResponse("failed", "Product not found", nil, err.Error()))
+		return
+	}
+
+	respondWithProduct(c, product)
+}
+
+// GetProductBySlug handles retrieving a product by its slug
+// @Summary Retrieve a product by slug
+// @Description Get a product by its URL slug. When an old slug is used after a rename, a 301 is returned
+// @Description with the current slug in the Location header and response data.
+// @Tags Products
+// @Produce json
+// @Param slug path string true "Product slug"
+// @Param locale query string false "Locale for name and description, e.g. pt-BR; overrides Accept-Language"
+// @Param Accept-Language header string false "Preferred locales; untranslated content falls back to the default locale"
+// @Success 200 {object} utils.Response{data=models.Product} "Product retrieved successfully"
+// @Success 304 "Not modified"
+// @Failure 301 {object} utils.Response{data=gin.H} "Product has moved to a new slug"
+// @Failure 404 {object} utils.Response "Product not found"
+// @Router /products/slug/{slug} [get]
+func GetProductBySlug(c *gin.Context) {
+	slug := c.Param("slug")
+
+	var product models.Product
+	err := visibleProducts(c).Preload("Components.Component").Where("slug = ?", slug).First(&product).Error
+	if err == nil {
+		respondWithProduct(c, product)
+		return
+	}
+
+	var redirect models.ProductSlugRedirect
+	if err := database.DB.Preload("Product").Where("slug = ?", slug).First(&redirect).Error; err != nil {
+		c.JSON(http.StatusNotFound, utils.GenerateResponse("failed", "Product not found", nil, err.Error()))
+		return
+	}
+	if redirect.Product.Status != services.ProductPublished && !services.IsPreOrder(redirect.Product) && !utils.IsAdmin(c) {
+		c.JSON(http.StatusNotFound, utils.GenerateResponse("failed", "Product not found", nil, ""))
+		return
+	}
+
+	location := "/api/products/slug/" + redirect.Product.Slug
+	c.Header("Location", location)
+	c.JSON(http.StatusMovedPermanently, utils.GenerateResponse("success", "Product has moved to a new slug", gin.H{
+		"product_id": redirect.ProductID,
+		"slug":       redirect.Product.Slug,
+		"location":   location,
+	}, ""))
+}
+
 // UpdateProduct handles updating a product (admin only)
 // @Summary Update an existing product
-// @Description Admins can update product details by providing the product ID and new data
+// @Description Admins can update product details by providing the product ID and new data.
+// @Description A change of stock is applied to the default warehouse; other warehouses are managed through their stock levels.
+// @Description The If-Match header must carry the product's current ETag; stale writes are rejected.
 // @Tags Products
 // @Accept json
 // @Produce json
 // @Param id path string true "Product ID"
-// @Param product body models.Product true "Updated product data"
-// @Success 200 {object} utils.Response "Product updated successfully"
-// @Failure 400 {object} utils.Response "Invalid request data"
+// @Param If-Match header string true "Current ETag of the product"
+// @Param product body models.ProductInput true "Updated product data"
+// @Success 200 {object} utils.Response{data=models.Product} "Product updated successfully"
+// @Failure 400 {object} utils.Response{data=[]utils.FieldError} "Invalid request data"
 // @Failure 403 {object} utils.Response "Unauthorized action"
 // @Failure 404 {object} utils.Response "Product not found"
+// @Failure 409 {object} utils.Response "Stock is held in other warehouses or reserved by pending orders"
+// @Failure 412 {object} utils.Response "Product was modified by another request"
+// @Failure 428 {object} utils.Response "If-Match header is required"
 // @Failure 500 {object} utils.Response "Failed to update product"
 // @Router /products/{id} [put]
 func UpdateProduct(c *gin.Context) {
@@ -75,9 +233,8 @@ func UpdateProduct(c *gin.Context) {
 	}
 
 	productID := c.Param("id")
-	var input models.Product
-	if err := c.ShouldBindJSON(&input); err != nil {
-		c.JSON(http.StatusBadRequest, utils.GenerateResponse("failed", "Invalid request data", nil, err.Error()))
+	var input models.ProductInput
+	if !bindProductInput(c, &input, false) {
 		return
 	}
 
@@ -87,27 +244,90 @@ func UpdateProduct(c *gin.Context) {
 		return
 	}
 
+	if !checkIfMatch(c, utils.ETag(product.ID, product.Version)) {
+		return
+	}
+
+	adminID := c.MustGet("userID").(uint)
+	oldPrice := product.Price
+	oldStock := product.Stock
+	renamed := product.Name != input.Name
+	if input.SKU != "" {
+		product.SKU = input.SKU
+	}
 	product.Name = input.Name
 	product.Description = input.Description
-	product.Price = input.Price
-	product.Stock = input.Stock
-	if err := database.DB.Save(&product).Error; err != nil {
+	product.Price = *input.Price
+	// Stock may be negative while backordered, so updates that leave it out keep it as is
+	if input.Stock != nil {
+		product.Stock = *input.Stock
+	}
+	product.ReorderThreshold = input.ReorderThreshold
+	product.BackorderLimit = input.BackorderLimit
+	product.ExpectedAt = input.ExpectedAt
+	product.IsDigital = input.IsDigital
+	if input.DownloadLimit > 0 {
+		product.DownloadLimit = input.DownloadLimit
+	}
+	if input.Attributes != nil {
+		product.Attributes = input.Attributes
+	}
+	if input.BackorderPolicy != "" {
+		product.BackorderPolicy = input.BackorderPolicy
+	}
+	if !applyLifecycle(c, &product, input) {
+		return
+	}
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if renamed {
+			if err := services.AssignSlug(tx, &product); err != nil {
+				return err
+			}
+		}
+		if err := saveVersioned(tx, &product, &product.Version); err != nil {
+			return err
+		}
+		if err := services.RecordPriceChange(tx, product.ID, oldPrice, product.Price, services.PriceSourceManual, &adminID, nil); err != nil {
+			return err
+		}
+		change := services.StockChange{Reason: services.MovementAdjustment, ActorID: &adminID}
+		if err := services.SyncDefaultStockLevel(tx, product, oldStock, product.Stock, change); err != nil {
+			return err
+		}
+		return services.AfterStockChange(tx, product, oldStock)
+	})
+	if err != nil {
+		if errors.Is(err, errVersionConflict) {
+			c.JSON(http.StatusPreconditionFailed, utils.GenerateResponse("failed", "Product has been modified by another request", nil, err.Error()))
+			return
+		}
+		if errors.Is(err, services.ErrStockHeldElsewhere) {
+			c.JSON(http.StatusConflict, utils.GenerateResponse("failed", "Stock is held in other warehouses", nil, err.Error()))
+			return
+		}
+		if errors.Is(err, services.ErrInvalidStockLevel) {
+			c.JSON(http.StatusConflict, utils.GenerateResponse("failed", "Stock is reserved by pending orders", nil, err.Error()))
+			return
+		}
 		c.JSON(http.StatusInternalServerError, utils.GenerateResponse("failed", "Failed to update product", nil, err.Error()))
 		return
 	}
 
+	c.Header("ETag", utils.ETag(product.ID, product.Version))
 	c.JSON(http.StatusOK, utils.GenerateResponse("success", "Product updated successfully", product, ""))
 }
 
 // DeleteProduct handles deleting a product (admin only)
 // @Summary Delete a product
-// @Description Admins can delete a product by providing the product ID
+// @Description Admins can delete a product by providing the product ID.
+// @Description Orders keep the product's name, SKU and attributes as they were when ordered.
 // @Tags Products
 // @Produce json
 // @Param id path string true "Product ID"
 // @Success 200 {object} utils.Response "Product deleted successfully"
 // @Failure 403 {object} utils.Response "Unauthorized action"
 // @Failure 404 {object} utils.Response "Product not found"
+// @Failure 409 {object} utils.Response{data=gin.H} "Product is part of a bundle or reserved by pending orders"
 // @Failure 500 {object} utils.Response "Failed to delete product"
 // @Router /products/{id} [delete]
 func DeleteProduct(c *gin.Context) {
@@ -123,6 +343,27 @@ func DeleteProduct(c *gin.Context) {
 		return
 	}
 
+	var bundles []uint
+	if err := database.DB.Model(&models.BundleComponent{}).Where("component_id = ?", product.ID).Pluck("bundle_id", &bundles).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, utils.GenerateResponse("failed", "Failed to delete product", nil, err.Error()))
+		return
+	}
+	if len(bundles) > 0 {
+		c.JSON(http.StatusConflict, utils.GenerateResponse("failed", "Product is part of a bundle", gin.H{"bundle_ids": bundles}, "remove it from its bundles before deleting it"))
+		return
+	}
+
+	// Past orders keep their snapshot of the product, but pending ones still need its stock
+	var reserved int64
+	if err := database.DB.Model(&models.StockReservation{}).Where("product_id = ? AND status = ?", product.ID, services.ReservationActive).Count(&reserved).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, utils.GenerateResponse("failed", "Failed to delete product", nil, err.Error()))
+		return
+	}
+	if reserved > 0 {
+		c.JSON(http.StatusConflict, utils.GenerateResponse("failed", "Product is reserved by pending orders", nil, "wait for the pending orders to be paid or cancelled"))
+		return
+	}
+
 	if err := database.DB.Delete(&product).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, utils.GenerateResponse("failed", "Failed to delete product", nil, err.Error()))
 		return
@@ -130,3 +371,110 @@ func DeleteProduct(c *gin.Context) {
 
 	c.JSON(http.StatusOK, utils.GenerateResponse("success", "Product deleted successfully", nil, ""))
 }
+
+// bindProductInput binds and validates a product payload, including its custom attributes.
+// New products must give their stock. An update that leaves attributes out keeps the product's
+// own, so they are not checked then.
+// It writes a 400 response with field-level details and returns false when the payload is invalid.
+func bindProductInput(c *gin.Context, input *models.ProductInput, creating bool) bool {
+	if err := c.ShouldBindJSON(input); err != nil {
+		c.JSON(http.StatusBadRequest, utils.GenerateResponse("failed", "Invalid request data", utils.FieldErrors(err), err.Error()))
+		return false
+	}
+	if creating && input.Stock == nil {
+		details := []utils.FieldError{{Field: "stock", Rule: "required", Message: "is required"}}
+		c.JSON(http.StatusBadRequest, utils.GenerateResponse("failed", "Invalid request data", details, "stock is required"))
+		return false
+	}
+	if !creating && input.Attributes == nil {
+		return true
+	}
+
+	problems, err := services.ValidateAttributes(database.DB, input.Attributes)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, utils.GenerateResponse("failed", "Failed to validate product attributes", nil, err.Error()))
+		return false
+	}
+
+	if len(problems) > 0 {
+		details := make([]utils.FieldError, len(problems))
+		for i, problem := range problems {
+			details[i] = utils.FieldError{Field: "attributes." + problem.Key, Rule: "attribute", Message: problem.Message}
+		}
+		c.JSON(http.StatusBadRequest, utils.GenerateResponse("failed", "Invalid request data", details, ""))
+		return false
+	}
+
+	return true
+}
+
+// applyLifecycle applies the publishing fields of input to product, responding with 400 if they are inconsistent
+func applyLifecycle(c *gin.Context, product *models.Product, input models.ProductInput) bool {
+	err := services.SetLifecycle(product, input.Status, input.PublishAt, input.UnpublishAt, time.Now())
+	var lifecycleErr *services.LifecycleError
+	if errors.As(err, &lifecycleErr) {
+		details := []utils.FieldError{{Field: lifecycleErr.Field, Rule: "lifecycle", Message: lifecycleErr.Message}}
+		c.JSON(http.StatusBadRequest, utils.GenerateResponse("failed", "Invalid request data", details, err.Error()))
+		return false
+	}
+	return true
+}
+
+// visibleProducts starts a product query limited to what the caller may see:
+// admins see every product, everyone else only published ones and pre-orders
+func visibleProducts(c *gin.Context) *gorm.DB {
+	if utils.IsAdmin(c) {
+		return database.DB
+	}
+	return database.DB.Scopes(services.SellableProducts)
+}
+
+// requestLocales resolves the locales the client asked for product content in. Responses vary
+// with Accept-Language, so the Vary header is set here, before any conditional response is sent.
+func requestLocales(c *gin.Context) []string {
+	c.Header("Vary", "Accept-Language")
+	return utils.LocaleChain(c.Query("locale"), c.GetHeader("Accept-Language"))
+}
+
+// respondWithProduct presents a single product and answers with it, or with 304 when the
+// client's If-None-Match already matches the presented product
+func respondWithProduct(c *gin.Context, product models.Product) {
+	products := []models.Product{product}
+	if err := presentProducts(products, requestLocales(c)); err != nil {
+		c.JSON(http.StatusInternalServerError, utils.GenerateResponse("failed", "Failed to fetch product", nil, err.Error()))
+		return
+	}
+	product = products[0]
+
+	etag, err := presentedETag(product)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, utils.GenerateResponse("failed", "Failed to fetch product", nil, err.Error()))
+		return
+	}
+	if notModified(c, etag) {
+		return
+	}
+
+	c.JSON(http.StatusOK, utils.GenerateResponse("success", "Product retrieved successfully", product, ""))
+}
+
+// presentedETag tags a presented product by its version and by everything presenting filled in,
+// so that a change of availability, e.g. from a reservation, or of locale gives a new tag even
+// though the product row itself did not change
+func presentedETag(product models.Product) (string, error) {
+	body, err := json.Marshal(product)
+	if err != nil {
+		return "", err
+	}
+	return utils.VariantETag(utils.ETag(product.ID, product.Version), string(body)), nil
+}
+
+// presentProducts fills in the request-dependent parts of products before they are returned:
+// their current availability and their content in the locales the client asked for
+func presentProducts(products []models.Product, locales []string) error {
+	if err := services.LoadAvailability(database.DB, products); err != nil {
+		return err
+	}
+
+	return services.Localize(database.DB, products, locales)
+}
@@ -0,0 +1,233 @@
+package controllers
+
+import (
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"go-ecommerce-api/database"
+	"go-ecommerce-api/models"
+	"go-ecommerce-api/services"
+	"go-ecommerce-api/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// ImportProducts handles bulk product imports from CSV or NDJSON (admin only)
+// @Summary Import products
+// @Description Upsert products by SKU from a CSV or NDJSON file, sent either as the "file" form field or as the raw request body.
+// @Description With async=true the file is queued and the job can be polled; otherwise the import runs before responding.
+// @Description The description and stock columns are optional: when they are missing or empty, existing products keep their values.
+// @Description New products are created as drafts.
+// @Tags Products
+// @Accept mpfd,text/csv,application/x-ndjson
+// @Produce json
+// @Param format query string false "Input format, inferred from the file name or content type when omitted" Enums(csv, ndjson)
+// @Param dry_run query bool false "Validate and report without writing any products"
+// @Param async query bool false "Process the import in the background"
+// @Param file formData file false "Import file"
+// @Success 200 {object} utils.Response{data=models.ProductImportJob} "Import completed"
+// @Success 202 {object} utils.Response{data=models.ProductImportJob} "Import queued"
+// @Failure 400 {object} utils.Response "Invalid import request"
+// @Failure 403 {object} utils.Response "Unauthorized action"
+// @Failure 422 {object} utils.Response "Import file could not be read"
+// @Failure 500 {object} utils.Response "Failed to import products"
+// @Router /products/import [post]
+func ImportProducts(c *gin.Context) {
+	if !utils.IsAdmin(c) {
+		c.JSON(http.StatusForbidden, utils.GenerateResponse("failed", "You are not authorized to perform this action", nil, ""))
+		return
+	}
+
+	body, filename, err := importSource(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, utils.GenerateResponse("failed", "Invalid import request", nil, err.Error()))
+		return
+	}
+	defer body.Close()
+
+	format := importFormat(c.Query("format"), filename, c.ContentType())
+	if format == "" {
+		c.JSON(http.StatusBadRequest, utils.GenerateResponse("failed", "Invalid import request", nil, "format must be csv or ndjson"))
+		return
+	}
+
+	job := models.ProductImportJob{
+		Format:    format,
+		DryRun:    c.Query("dry_run") == "true",
+		Status:    "queued",
+		CreatedBy: c.MustGet("userID").(uint),
+	}
+
+	if c.Query("async") != "true" {
+		if err := database.DB.Create(&job).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, utils.GenerateResponse("failed", "Failed to import products", nil, err.Error()))
+			return
+		}
+
+		if err := services.RunProductImport(&job, body); err != nil {
+			c.JSON(http.StatusUnprocessableEntity, utils.GenerateResponse("failed", "Import file could not be read", job, err.Error()))
+			return
+		}
+
+		database.DB.Where("job_id = ?", job.ID).Order("row").Find(&job.Errors)
+		c.JSON(http.StatusOK, utils.GenerateResponse("success", "Import completed", job, ""))
+		return
+	}
+
+	// The request body is gone once the handler returns, so spool it to disk for the worker
+	spool, err := os.CreateTemp("", "product-import-*")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, utils.GenerateResponse("failed", "Failed to import products", nil, err.Error()))
+		return
+	}
+
+	job.TotalBytes, err = io.Copy(spool, body)
+	spool.Close()
+	if err != nil {
+		os.Remove(spool.Name())
+		c.JSON(http.StatusBadRequest, utils.GenerateResponse("failed", "Invalid import request", nil, err.Error()))
+		return
+	}
+
+	if err := database.DB.Create(&job).Error; err != nil {
+		os.Remove(spool.Name())
+		c.JSON(http.StatusInternalServerError, utils.GenerateResponse("failed", "Failed to import products", nil, err.Error()))
+		return
+	}
+
+	go runSpooledImport(job, spool.Name())
+
+	c.JSON(http.StatusAccepted, utils.GenerateResponse("success", "Import queued", job, ""))
+}
+
+// GetImportJob reports the progress and row errors of a product import (admin only)
+// @Summary Get product import status
+// @Description Fetch the status, progress counters and per-row errors of a product import job.
+// @Tags Products
+// @Produce json
+// @Param id path int true "Import job ID"
+// @Success 200 {object} utils.Response{data=models.ProductImportJob} "Import job retrieved successfully"
+// @Failure 403 {object} utils.Response "Unauthorized action"
+// @Failure 404 {object} utils.Response "Import job not found"
+// @Router /products/import/{id} [get]
+func GetImportJob(c *gin.Context) {
+	if !utils.IsAdmin(c) {
+		c.JSON(http.StatusForbidden, utils.GenerateResponse("failed", "You are not authorized to perform this action", nil, ""))
+		return
+	}
+
+	var job models.ProductImportJob
+	if err := database.DB.Preload("Errors", func(tx *gorm.DB) *gorm.DB { return tx.Order("row") }).First(&job, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, utils.GenerateResponse("failed", "Import job not found", nil, err.Error()))
+		return
+	}
+
+	c.JSON(http.StatusOK, utils.GenerateResponse("success", "Import job retrieved successfully", job, ""))
+}
+
+// ExportProducts streams the catalog as CSV or NDJSON (admin only)
+// @Summary Export products
+// @Description Stream every product as CSV or NDJSON, in the same layout the import endpoint accepts.
+// @Tags Products
+// @Produce text/csv,application/x-ndjson
+// @Param format query string false "Output format" Enums(csv, ndjson) default(csv)
+// @Success 200 {file} file "Product export"
+// @Failure 400 {object} utils.Response "Invalid export format"
+// @Failure 403 {object} utils.Response "Unauthorized action"
+// @Router /products/export [get]
+func ExportProducts(c *gin.Context) {
+	if !utils.IsAdmin(c) {
+		c.JSON(http.StatusForbidden, utils.GenerateResponse("failed", "You are not authorized to perform this action", nil, ""))
+		return
+	}
+
+	format := c.DefaultQuery("format", services.FormatCSV)
+	contentType := "text/csv"
+	switch format {
+	case services.FormatCSV:
+	case services.FormatNDJSON:
+		contentType = "application/x-ndjson"
+	default:
+		c.JSON(http.StatusBadRequest, utils.GenerateResponse("failed", "Invalid export format", nil, "format must be csv or ndjson"))
+		return
+	}
+
+	c.Header("Content-Type", contentType)
+	c.Header("Content-Disposition", "attachment; filename=products."+format)
+	c.Status(http.StatusOK)
+
+	// Headers are already sent, so a failure part-way through can only be logged
+	if err := services.ExportProducts(c.Writer, format, c.Writer.Flush); err != nil {
+		logrus.WithError(err).Error("Product export failed")
+	}
+}
+
+// importSource returns the uploaded file when the request is multipart, otherwise the raw body
+func importSource(c *gin.Context) (io.ReadCloser, string, error) {
+	if !strings.HasPrefix(c.ContentType(), "multipart/") {
+		return c.Request.Body, "", nil
+	}
+
+	header, err := c.FormFile("file")
+	if err != nil {
+		return nil, "", err
+	}
+
+	file, err := header.Open()
+	if err != nil {
+		return nil, "", err
+	}
+
+	return file, header.Filename, nil
+}
+
+// importFormat resolves the import format from the query, the file extension or the content type
+func importFormat(requested, filename, contentType string) string {
+	switch strings.ToLower(requested) {
+	case services.FormatCSV:
+		return services.FormatCSV
+	case services.FormatNDJSON, "jsonl":
+		return services.FormatNDJSON
+	case "":
+	default:
+		return ""
+	}
+
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".csv":
+		return services.FormatCSV
+	case ".ndjson", ".jsonl":
+		return services.FormatNDJSON
+	}
+
+	switch contentType {
+	case "text/csv":
+		return services.FormatCSV
+	case "application/x-ndjson", "application/jsonl":
+		return services.FormatNDJSON
+	}
+
+	return ""
+}
+
+// runSpooledImport processes a queued import in the background and removes its spool file
+func runSpooledImport(job models.ProductImportJob, path string) {
+	defer os.Remove(path)
+
+	file, err := os.Open(path)
+	if err != nil {
+		logrus.WithError(err).WithField("job_id", job.ID).Error("Failed to open product import spool")
+		database.DB.Model(&job).Updates(map[string]interface{}{"status": "failed", "error": err.Error()})
+		return
+	}
+	defer file.Close()
+
+	if err := services.RunProductImport(&job, file); err != nil {
+		logrus.WithError(err).WithField("job_id", job.ID).Error("Product import failed")
+	}
+}
@@ -0,0 +1,189 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+
+	"go-ecommerce-api/database"
+	"go-ecommerce-api/models"
+	"go-ecommerce-api/services"
+	"go-ecommerce-api/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetRecommendations lists products frequently bought together with a product
+// @Summary Get product recommendations
+// @Description Suggest products for cross-selling: admin-pinned products first, then the products most often bought in the same order.
+// @Tags Recommendations
+// @Produce json
+// @Param id path int true "Product ID"
+// @Param limit query int false "Maximum number of recommendations" default(10)
+// @Success 200 {object} utils.Response{data=[]services.Recommendation} "Recommendations retrieved successfully"
+// @Failure 400 {object} utils.Response "Invalid limit"
+// @Failure 404 {object} utils.Response "Product not found"
+// @Failure 500 {object} utils.Response "Failed to fetch recommendations"
+// @Router /products/{id}/recommendations [get]
+func GetRecommendations(c *gin.Context) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "10"))
+	if err != nil || limit < 1 || limit > 50 {
+		c.JSON(http.StatusBadRequest, utils.GenerateResponse("failed", "Invalid limit", nil, "limit must be between 1 and 50"))
+		return
+	}
+
+	var product models.Product
+	if err := visibleProducts(c).First(&product, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, utils.GenerateResponse("failed", "Product not found", nil, err.Error()))
+		return
+	}
+
+	recommendations, err := services.RecommendationsFor(product.ID, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, utils.GenerateResponse("failed", "Failed to fetch recommendations", nil, err.Error()))
+		return
+	}
+
+	c.JSON(http.StatusOK, utils.GenerateResponse("success", "Recommendations retrieved successfully", recommendations, ""))
+}
+
+// ListRecommendationOverrides lists the pinned and excluded recommendations of a product (admin only)
+// @Summary List recommendation overrides
+// @Description List the recommendations an admin has pinned or excluded for a product.
+// @Tags Recommendations
+// @Produce json
+// @Param id path int true "Product ID"
+// @Success 200 {object} utils.Response{data=[]models.RecommendationOverride} "Recommendation overrides retrieved successfully"
+// @Failure 403 {object} utils.Response "Unauthorized action"
+// @Failure 500 {object} utils.Response "Failed to fetch recommendation overrides"
+// @Router /products/{id}/recommendation-overrides [get]
+func ListRecommendationOverrides(c *gin.Context) {
+	if !utils.IsAdmin(c) {
+		c.JSON(http.StatusForbidden, utils.GenerateResponse("failed", "You are not authorized to perform this action", nil, ""))
+		return
+	}
+
+	var overrides []models.RecommendationOverride
+	if err := database.DB.Where("product_id = ?", c.Param("id")).Order("action, position, id").Find(&overrides).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, utils.GenerateResponse("failed", "Failed to fetch recommendation overrides", nil, err.Error()))
+		return
+	}
+
+	c.JSON(http.StatusOK, utils.GenerateResponse("success", "Recommendation overrides retrieved successfully", overrides, ""))
+}
+
+// SetRecommendationOverride pins or excludes a recommended product (admin only)
+// @Summary Pin or exclude a recommendation
+// @Description Pin a product to always be recommended for another, or exclude it from the recommendations. Setting an override again replaces it.
+// @Tags Recommendations
+// @Accept json
+// @Produce json
+// @Param id path int true "Product ID"
+// @Param override body object{related_product_id=int,action=string,position=int} true "Recommendation override"
+// @Success 200 {object} utils.Response{data=models.RecommendationOverride} "Recommendation override saved successfully"
+// @Failure 400 {object} utils.Response "Invalid request data"
+// @Failure 403 {object} utils.Response "Unauthorized action"
+// @Failure 404 {object} utils.Response "Product not found"
+// @Failure 500 {object} utils.Response "Failed to save recommendation override"
+// @Router /products/{id}/recommendation-overrides [post]
+func SetRecommendationOverride(c *gin.Context) {
+	if !utils.IsAdmin(c) {
+		c.JSON(http.StatusForbidden, utils.GenerateResponse("failed", "You are not authorized to perform this action", nil, ""))
+		return
+	}
+
+	var input struct {
+		RelatedProductID uint   `json:"related_product_id" binding:"required"`
+		Action           string `json:"action" binding:"required,oneof=pin exclude"`
+		Position         int    `json:"position" binding:"gte=0"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, utils.GenerateResponse("failed", "Invalid request data", utils.FieldErrors(err), err.Error()))
+		return
+	}
+
+	var product models.Product
+	if err := database.DB.First(&product, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, utils.GenerateResponse("failed", "Product not found", nil, err.Error()))
+		return
+	}
+
+	if input.RelatedProductID == product.ID {
+		c.JSON(http.StatusBadRequest, utils.GenerateResponse("failed", "Invalid request data", nil, "a product cannot be recommended for itself"))
+		return
+	}
+
+	var related models.Product
+	if err := database.DB.First(&related, input.RelatedProductID).Error; err != nil {
+		c.JSON(http.StatusNotFound, utils.GenerateResponse("failed", "Product not found", nil, err.Error()))
+		return
+	}
+
+	var override models.RecommendationOverride
+	if err := database.DB.FirstOrInit(&override, models.RecommendationOverride{ProductID: product.ID, RelatedProductID: related.ID}).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, utils.GenerateResponse("failed", "Failed to save recommendation override", nil, err.Error()))
+		return
+	}
+	override.Action = input.Action
+	override.Position = input.Position
+	override.CreatedBy = c.MustGet("userID").(uint)
+	if err := database.DB.Save(&override).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, utils.GenerateResponse("failed", "Failed to save recommendation override", nil, err.Error()))
+		return
+	}
+
+	c.JSON(http.StatusOK, utils.GenerateResponse("success", "Recommendation override saved successfully", override, ""))
+}
+
+// DeleteRecommendationOverride removes a pinned or excluded recommendation (admin only)
+// @Summary Remove a recommendation override
+// @Description Remove an override so the recommendation falls back to purchase data.
+// @Tags Recommendations
+// @Produce json
+// @Param id path int true "Recommendation override ID"
+// @Success 200 {object} utils.Response "Recommendation override removed successfully"
+// @Failure 403 {object} utils.Response "Unauthorized action"
+// @Failure 404 {object} utils.Response "Recommendation override not found"
+// @Failure 500 {object} utils.Response "Failed to remove recommendation override"
+// @Router /recommendation-overrides/{id} [delete]
+func DeleteRecommendationOverride(c *gin.Context) {
+	if !utils.IsAdmin(c) {
+		c.JSON(http.StatusForbidden, utils.GenerateResponse("failed", "You are not authorized to perform this action", nil, ""))
+		return
+	}
+
+	var override models.RecommendationOverride
+	if err := database.DB.First(&override, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, utils.GenerateResponse("failed", "Recommendation override not found", nil, err.Error()))
+		return
+	}
+
+	if err := database.DB.Delete(&override).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, utils.GenerateResponse("failed", "Failed to remove recommendation override", nil, err.Error()))
+		return
+	}
+
+	c.JSON(http.StatusOK, utils.GenerateResponse("success", "Recommendation override removed successfully", nil, ""))
+}
+
+// RefreshRecommendations recomputes co-purchase affinities immediately (admin only)
+// @Summary Refresh recommendations
+// @Description Rebuild co-purchase affinities from order history now instead of waiting for the hourly refresh.
+// @Tags Recommendations
+// @Produce json
+// @Success 200 {object} utils.Response "Recommendations refreshed successfully"
+// @Failure 403 {object} utils.Response "Unauthorized action"
+// @Failure 500 {object} utils.Response "Failed to refresh recommendations"
+// @Router /recommendations/refresh [post]
+func RefreshRecommendations(c *gin.Context) {
+	if !utils.IsAdmin(c) {
+		c.JSON(http.StatusForbidden, utils.GenerateResponse("failed", "You are not authorized to perform this action", nil, ""))
+		return
+	}
+
+	if err := services.RefreshProductAffinities(); err != nil {
+		c.JSON(http.StatusInternalServerError, utils.GenerateResponse("failed", "Failed to refresh recommendations", nil, err.Error()))
+		return
+	}
+
+	c.JSON(http.StatusOK, utils.GenerateResponse("success", "Recommendations refreshed successfully", nil, ""))
+}
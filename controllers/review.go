@@ -0,0 +1,214 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+
+	"go-ecommerce-api/database"
+	"go-ecommerce-api/models"
+	"go-ecommerce-api/services"
+	"go-ecommerce-api/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CreateReview lets a verified buyer review a product
+// @Summary Review a product
+// @Description Post a star rating and review for a product. Only users with a completed order containing the product may post, once per product. Reviews are published after moderation.
+// @Tags Reviews
+// @Accept json
+// @Produce json
+// @Param id path int true "Product ID"
+// @Param review body object{rating=int,title=string,body=string} true "Review"
+// @Success 201 {object} utils.Response{data=models.Review} "Review submitted for moderation"
+// @Failure 400 {object} utils.Response "Invalid request data"
+// @Failure 403 {object} utils.Response "Only verified buyers can review this product"
+// @Failure 404 {object} utils.Response "Product not found"
+// @Failure 409 {object} utils.Response "You have already reviewed this product"
+// @Failure 500 {object} utils.Response "Failed to submit review"
+// @Security ApiKeyAuth
+// @Router /products/{id}/reviews [post]
+func CreateReview(c *gin.Context) {
+	var input struct {
+		Rating int    `json:"rating" binding:"required,gte=1,lte=5"`
+		Title  string `json:"title" binding:"required,notblank,max=150"`
+		Body   string `json:"body" binding:"max=5000"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, utils.GenerateResponse("failed", "Invalid request data", utils.FieldErrors(err), err.Error()))
+		return
+	}
+
+	var product models.Product
+	if err := visibleProducts(c).First(&product, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, utils.GenerateResponse("failed", "Product not found", nil, err.Error()))
+		return
+	}
+
+	userID := c.MustGet("userID").(uint)
+	purchased, err := services.HasPurchased(userID, product.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, utils.GenerateResponse("failed", "Failed to submit review", nil, err.Error()))
+		return
+	}
+	if !purchased {
+		c.JSON(http.StatusForbidden, utils.GenerateResponse("failed", "Only verified buyers can review this product", nil, ""))
+		return
+	}
+
+	var existing int64
+	if err := database.DB.Model(&models.Review{}).Where("product_id = ? AND user_id = ?", product.ID, userID).Count(&existing).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, utils.GenerateResponse("failed", "Failed to submit review", nil, err.Error()))
+		return
+	}
+	if existing > 0 {
+		c.JSON(http.StatusConflict, utils.GenerateResponse("failed", "You have already reviewed this product", nil, ""))
+		return
+	}
+
+	review := models.Review{
+		ProductID: product.ID,
+		UserID:    userID,
+		Rating:    input.Rating,
+		Title:     input.Title,
+		Body:      input.Body,
+		Status:    "pending",
+	}
+	if err := database.DB.Create(&review).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, utils.GenerateResponse("failed", "Failed to submit review", nil, err.Error()))
+		return
+	}
+
+	c.JSON(http.StatusCreated, utils.GenerateResponse("success", "Review submitted for moderation", review, ""))
+}
+
+// ListProductReviews lists the approved reviews of a product
+// @Summary List product reviews
+// @Description Get the approved reviews of a product, newest first.
+// @Tags Reviews
+// @Produce json
+// @Param id path int true "Product ID"
+// @Success 200 {object} utils.Response{data=[]models.Review} "Reviews retrieved successfully"
+// @Failure 404 {object} utils.Response "Product not found"
+// @Failure 500 {object} utils.Response "Failed to fetch reviews"
+// @Router /products/{id}/reviews [get]
+func ListProductReviews(c *gin.Context) {
+	var product models.Product
+	if err := visibleProducts(c).Select("id").First(&product, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, utils.GenerateResponse("failed", "Product not found", nil, err.Error()))
+		return
+	}
+
+	var reviews []models.Review
+	if err := database.DB.Where("product_id = ? AND status = ?", product.ID, "approved").Order("created_at DESC").Find(&reviews).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, utils.GenerateResponse("failed", "Failed to fetch reviews", nil, err.Error()))
+		return
+	}
+
+	c.JSON(http.StatusOK, utils.GenerateResponse("success", "Reviews retrieved successfully", reviews, ""))
+}
+
+// ListReviews lists reviews by moderation status (admin only)
+// @Summary List reviews for moderation
+// @Description Get reviews in a moderation state, oldest first. Defaults to pending reviews.
+// @Tags Reviews
+// @Produce json
+// @Param status query string false "Moderation status" Enums(pending, approved, rejected) default(pending)
+// @Success 200 {object} utils.Response{data=[]models.Review} "Reviews retrieved successfully"
+// @Failure 400 {object} utils.Response "Invalid status"
+// @Failure 403 {object} utils.Response "Unauthorized action"
+// @Failure 500 {object} utils.Response "Failed to fetch reviews"
+// @Router /reviews [get]
+func ListReviews(c *gin.Context) {
+	if !utils.IsAdmin(c) {
+		c.JSON(http.StatusForbidden, utils.GenerateResponse("failed", "You are not authorized to perform this action", nil, ""))
+		return
+	}
+
+	status := c.DefaultQuery("status", "pending")
+	if status != "pending" && status != "approved" && status != "rejected" {
+		c.JSON(http.StatusBadRequest, utils.GenerateResponse("failed", "Invalid status", nil, "status must be pending, approved or rejected"))
+		return
+	}
+
+	var reviews []models.Review
+	if err := database.DB.Where("status = ?", status).Order("created_at").Find(&reviews).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, utils.GenerateResponse("failed", "Failed to fetch reviews", nil, err.Error()))
+		return
+	}
+
+	c.JSON(http.StatusOK, utils.GenerateResponse("success", "Reviews retrieved successfully", reviews, ""))
+}
+
+// ApproveReview publishes a pending review (admin only)
+// @Summary Approve a review
+// @Description Publish a pending review and include it in the product's rating.
+// @Tags Reviews
+// @Accept json
+// @Produce json
+// @Param id path int true "Review ID"
+// @Param moderation body object{note=string} false "Optional moderation note"
+// @Success 200 {object} utils.Response{data=models.Review} "Review approved successfully"
+// @Failure 400 {object} utils.Response "Invalid request data"
+// @Failure 403 {object} utils.Response "Unauthorized action"
+// @Failure 404 {object} utils.Response "Review not found"
+// @Failure 409 {object} utils.Response "Review has already been moderated"
+// @Failure 500 {object} utils.Response "Failed to moderate review"
+// @Router /reviews/{id}/approve [put]
+func ApproveReview(c *gin.Context) {
+	moderateReview(c, "approved", "Review approved successfully")
+}
+
+// RejectReview rejects a pending review (admin only)
+// @Summary Reject a review
+// @Description Reject a pending review so it is never published.
+// @Tags Reviews
+// @Accept json
+// @Produce json
+// @Param id path int true "Review ID"
+// @Param moderation body object{note=string} false "Optional moderation note"
+// @Success 200 {object} utils.Response{data=models.Review} "Review rejected successfully"
+// @Failure 400 {object} utils.Response "Invalid request data"
+// @Failure 403 {object} utils.Response "Unauthorized action"
+// @Failure 404 {object} utils.Response "Review not found"
+// @Failure 409 {object} utils.Response "Review has already been moderated"
+// @Failure 500 {object} utils.Response "Failed to moderate review"
+// @Router /reviews/{id}/reject [put]
+func RejectReview(c *gin.Context) {
+	moderateReview(c, "rejected", "Review rejected successfully")
+}
+
+func moderateReview(c *gin.Context, status string, message string) {
+	if !utils.IsAdmin(c) {
+		c.JSON(http.StatusForbidden, utils.GenerateResponse("failed", "You are not authorized to perform this action", nil, ""))
+		return
+	}
+
+	// The note is optional, so an empty body is fine
+	var input struct {
+		Note string `json:"note"`
+	}
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, utils.GenerateResponse("failed", "Invalid request data", utils.FieldErrors(err), err.Error()))
+			return
+		}
+	}
+
+	var review models.Review
+	if err := database.DB.First(&review, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, utils.GenerateResponse("failed", "Review not found", nil, err.Error()))
+		return
+	}
+
+	if err := services.ModerateReview(&review, status, c.MustGet("userID").(uint), input.Note); err != nil {
+		if errors.Is(err, services.ErrReviewAlreadyModerated) {
+			c.JSON(http.StatusConflict, utils.GenerateResponse("failed", "Review has already been moderated", nil, err.Error()))
+			return
+		}
+		c.JSON(http.StatusInternalServerError, utils.GenerateResponse("failed", "Failed to moderate review", nil, err.Error()))
+		return
+	}
+
+	c.JSON(http.StatusOK, utils.GenerateResponse("success", message, review, ""))
+}
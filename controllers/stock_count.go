@@ -0,0 +1,374 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"go-ecommerce-api/database"
+	"go-ecommerce-api/models"
+	"go-ecommerce-api/services"
+	"go-ecommerce-api/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// CreateStockCount opens a physical stock count at a warehouse (admin only)
+// @Summary Start a stock count
+// @Description Open a count session at a warehouse. Counted quantities are uploaded to it, reviewed in the variance report
+// @Description and then applied to the warehouse's stock levels in one step.
+// @Tags Inventory
+// @Accept json
+// @Produce json
+// @Param count body object{warehouse_id=int,note=string} true "Warehouse to count and an optional note for the inventory ledger"
+// @Success 201 {object} utils.Response{data=models.StockCount} "Stock count created successfully"
+// @Failure 400 {object} utils.Response "Invalid request data"
+// @Failure 403 {object} utils.Response "Unauthorized action"
+// @Failure 404 {object} utils.Response "Warehouse not found"
+// @Failure 500 {object} utils.Response "Failed to create stock count"
+// @Router /inventory/counts [post]
+func CreateStockCount(c *gin.Context) {
+	if !utils.IsAdmin(c) {
+		c.JSON(http.StatusForbidden, utils.GenerateResponse("failed", "You are not authorized to perform this action", nil, ""))
+		return
+	}
+
+	var input struct {
+		WarehouseID uint   `json:"warehouse_id" binding:"required"`
+		Note        string `json:"note" binding:"max=500"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, utils.GenerateResponse("failed", "Invalid request data", utils.FieldErrors(err), err.Error()))
+		return
+	}
+
+	var warehouse models.Warehouse
+	if err := database.DB.First(&warehouse, input.WarehouseID).Error; err != nil {
+		c.JSON(http.StatusNotFound, utils.GenerateResponse("failed", "Warehouse not found", nil, err.Error()))
+		return
+	}
+
+	count := models.StockCount{
+		WarehouseID: warehouse.ID,
+		Status:      services.CountOpen,
+		Note:        input.Note,
+		CreatedBy:   c.MustGet("userID").(uint),
+	}
+	if err := database.DB.Create(&count).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, utils.GenerateResponse("failed", "Failed to create stock count", nil, err.Error()))
+		return
+	}
+
+	count.Warehouse = warehouse
+	c.JSON(http.StatusCreated, utils.GenerateResponse("success", "Stock count created successfully", count, ""))
+}
+
+// ListStockCounts lists stock counts, newest first (admin only)
+// @Summary List stock counts
+// @Description Get stock count sessions, newest first, without their lines.
+// @Tags Inventory
+// @Produce json
+// @Param status query string false "Only counts in this state" Enums(open, applied, cancelled)
+// @Param warehouse_id query int false "Only counts at this warehouse"
+// @Param page query int false "Page number" default(1)
+// @Param per_page query int false "Counts per page, at most 200" default(50)
+// @Success 200 {object} utils.Response{data=object{counts=[]models.StockCount,page=controllers.Page}} "Stock counts retrieved successfully"
+// @Failure 400 {object} utils.Response "Invalid pagination"
+// @Failure 403 {object} utils.Response "Unauthorized action"
+// @Failure 500 {object} utils.Response "Failed to fetch stock counts"
+// @Router /inventory/counts [get]
+func ListStockCounts(c *gin.Context) {
+	if !utils.IsAdmin(c) {
+		c.JSON(http.StatusForbidden, utils.GenerateResponse("failed", "You are not authorized to perform this action", nil, ""))
+		return
+	}
+
+	page, ok := pageParams(c, 50)
+	if !ok {
+		return
+	}
+
+	query := database.DB.Model(&models.StockCount{})
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if warehouseID := c.Query("warehouse_id"); warehouseID != "" {
+		query = query.Where("warehouse_id = ?", warehouseID)
+	}
+
+	// A new session lets the count and the page be built from the same filtered query
+	query = query.Session(&gorm.Session{})
+	if err := query.Count(&page.Total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, utils.GenerateResponse("failed", "Failed to fetch stock counts", nil, err.Error()))
+		return
+	}
+
+	var counts []models.StockCount
+	if err := paginate(query.Preload("Warehouse").Order("id DESC"), page).Find(&counts).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, utils.GenerateResponse("failed", "Failed to fetch stock counts", nil, err.Error()))
+		return
+	}
+
+	c.JSON(http.StatusOK, utils.GenerateResponse("success", "Stock counts retrieved successfully", gin.H{
+		"counts": counts,
+		"page":   page,
+	}, ""))
+}
+
+// GetStockCount fetches a stock count with its counted lines (admin only)
+// @Summary Get a stock count
+// @Description Get a stock count session and every quantity counted in it.
+// @Tags Inventory
+// @Produce json
+// @Param id path int true "Stock count ID"
+// @Success 200 {object} utils.Response{data=models.StockCount} "Stock count retrieved successfully"
+// @Failure 403 {object} utils.Response "Unauthorized action"
+// @Failure 404 {object} utils.Response "Stock count not found"
+// @Router /inventory/counts/{id} [get]
+func GetStockCount(c *gin.Context) {
+	if !utils.IsAdmin(c) {
+		c.JSON(http.StatusForbidden, utils.GenerateResponse("failed", "You are not authorized to perform this action", nil, ""))
+		return
+	}
+
+	var count models.StockCount
+	err := database.DB.Preload("Warehouse").
+		Preload("Lines", func(tx *gorm.DB) *gorm.DB { return tx.Order("product_id") }).
+		First(&count, c.Param("id")).Error
+	if err != nil {
+		c.JSON(http.StatusNotFound, utils.GenerateResponse("failed", "Stock count not found", nil, err.Error()))
+		return
+	}
+
+	c.JSON(http.StatusOK, utils.GenerateResponse("success", "Stock count retrieved successfully", count, ""))
+}
+
+// RecordStockCountLines uploads counted quantities to an open stock count (admin only)
+// @Summary Upload counted quantities
+// @Description Record counted quantities, either as JSON or as a CSV file with a quantity column and a product_id or sku column,
+// @Description sent as the "file" form field or as the raw request body. Recounting a product replaces its earlier quantity.
+// @Description Every entry is checked first; if any is rejected nothing is recorded and all problems are returned.
+// @Tags Inventory
+// @Accept json,mpfd,text/csv
+// @Produce json
+// @Param id path int true "Stock count ID"
+// @Param lines body object{lines=[]object{product_id=int,sku=string,quantity=int}} false "Counted quantities, identifying each product by ID or SKU"
+// @Param file formData file false "CSV of counted quantities"
+// @Success 200 {object} utils.Response{data=[]models.StockCountLine} "Counts recorded successfully"
+// @Failure 400 {object} utils.Response{data=[]services.CountProblem} "Invalid counts"
+// @Failure 403 {object} utils.Response "Unauthorized action"
+// @Failure 404 {object} utils.Response "Stock count not found"
+// @Failure 409 {object} utils.Response "Stock count is not open"
+// @Failure 500 {object} utils.Response "Failed to record counts"
+// @Router /inventory/counts/{id}/lines [post]
+func RecordStockCountLines(c *gin.Context) {
+	if !utils.IsAdmin(c) {
+		c.JSON(http.StatusForbidden, utils.GenerateResponse("failed", "You are not authorized to perform this action", nil, ""))
+		return
+	}
+
+	var count models.StockCount
+	if err := database.DB.First(&count, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, utils.GenerateResponse("failed", "Stock count not found", nil, err.Error()))
+		return
+	}
+
+	entries, ok := countEntries(c)
+	if !ok {
+		return
+	}
+
+	var lines []models.StockCountLine
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		lines, err = services.RecordCounts(tx, count.ID, entries)
+		return err
+	})
+	if err != nil {
+		var countErr *services.CountError
+		switch {
+		case errors.As(err, &countErr):
+			c.JSON(http.StatusBadRequest, utils.GenerateResponse("failed", "Invalid counts", countErr.Problems, err.Error()))
+		case errors.Is(err, services.ErrCountClosed):
+			c.JSON(http.StatusConflict, utils.GenerateResponse("failed", "Stock count is not open", nil, err.Error()))
+		default:
+			c.JSON(http.StatusInternalServerError, utils.GenerateResponse("failed", "Failed to record counts", nil, err.Error()))
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, utils.GenerateResponse("success", "Counts recorded successfully", lines, ""))
+}
+
+// GetStockCountVariance compares a stock count with the system's stock levels (admin only)
+// @Summary Stock count variance report
+// @Description Compare every counted quantity with the product's stock level at the count's warehouse, largest differences first.
+// @Description For an applied count the report shows the levels the count replaced.
+// @Tags Inventory
+// @Produce json
+// @Param id path int true "Stock count ID"
+// @Success 200 {object} utils.Response{data=services.VarianceReport} "Variance report retrieved successfully"
+// @Failure 403 {object} utils.Response "Unauthorized action"
+// @Failure 404 {object} utils.Response "Stock count not found"
+// @Failure 500 {object} utils.Response "Failed to build variance report"
+// @Router /inventory/counts/{id}/variance [get]
+func GetStockCountVariance(c *gin.Context) {
+	if !utils.IsAdmin(c) {
+		c.JSON(http.StatusForbidden, utils.GenerateResponse("failed", "You are not authorized to perform this action", nil, ""))
+		return
+	}
+
+	var count models.StockCount
+	if err := database.DB.Select("id").First(&count, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, utils.GenerateResponse("failed", "Stock count not found", nil, err.Error()))
+		return
+	}
+
+	report, err := services.StockCountVariance(database.DB, count.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, utils.GenerateResponse("failed", "Failed to build variance report", nil, err.Error()))
+		return
+	}
+
+	c.JSON(http.StatusOK, utils.GenerateResponse("success", "Variance report retrieved successfully", report, ""))
+}
+
+// ApplyStockCount sets stock levels to a count's quantities (admin only)
+// @Summary Apply a stock count
+// @Description Set the stock of every counted product at the count's warehouse to the counted quantity and close the count.
+// @Description Each difference is recorded as an adjustment in the inventory ledger. The whole count is applied or nothing is.
+// @Description A count below the stock reserved for pending orders at the warehouse is rejected; the variance report flags such lines.
+// @Tags Inventory
+// @Produce json
+// @Param id path int true "Stock count ID"
+// @Success 200 {object} utils.Response{data=services.VarianceReport} "Stock count applied successfully"
+// @Failure 400 {object} utils.Response "Counted product no longer tracks stock"
+// @Failure 403 {object} utils.Response "Unauthorized action"
+// @Failure 404 {object} utils.Response "Stock count not found"
+// @Failure 409 {object} utils.Response{data=[]services.CountProblem} "Stock count is not open, or counts are below reserved stock"
+// @Failure 500 {object} utils.Response "Failed to apply stock count"
+// @Router /inventory/counts/{id}/apply [post]
+func ApplyStockCount(c *gin.Context) {
+	if !utils.IsAdmin(c) {
+		c.JSON(http.StatusForbidden, utils.GenerateResponse("failed", "You are not authorized to perform this action", nil, ""))
+		return
+	}
+
+	var count models.StockCount
+	if err := database.DB.Select("id").First(&count, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, utils.GenerateResponse("failed", "Stock count not found", nil, err.Error()))
+		return
+	}
+
+	adminID := c.MustGet("userID").(uint)
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		_, err := services.ApplyStockCount(tx, count.ID, adminID)
+		return err
+	})
+	if err != nil {
+		var countErr *services.CountError
+		switch {
+		case errors.Is(err, services.ErrCountClosed):
+			c.JSON(http.StatusConflict, utils.GenerateResponse("failed", "Stock count is not open", nil, err.Error()))
+		case errors.As(err, &countErr):
+			c.JSON(http.StatusConflict, utils.GenerateResponse("failed", "Counts are below reserved stock", countErr.Problems, err.Error()))
+		case errors.Is(err, services.ErrInvalidStockLevel):
+			c.JSON(http.StatusBadRequest, utils.GenerateResponse("failed", "Counted product no longer tracks stock", nil, err.Error()))
+		default:
+			c.JSON(http.StatusInternalServerError, utils.GenerateResponse("failed", "Failed to apply stock count", nil, err.Error()))
+		}
+		return
+	}
+
+	report, err := services.StockCountVariance(database.DB, count.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, utils.GenerateResponse("failed", "Failed to build variance report", nil, err.Error()))
+		return
+	}
+
+	c.JSON(http.StatusOK, utils.GenerateResponse("success", "Stock count applied successfully", report, ""))
+}
+
+// CancelStockCount discards an open stock count (admin only)
+// @Summary Cancel a stock count
+// @Description Close an open stock count without changing any stock.
+// @Tags Inventory
+// @Produce json
+// @Param id path int true "Stock count ID"
+// @Success 200 {object} utils.Response{data=models.StockCount} "Stock count cancelled successfully"
+// @Failure 403 {object} utils.Response "Unauthorized action"
+// @Failure 404 {object} utils.Response "Stock count not found"
+// @Failure 409 {object} utils.Response "Stock count is not open"
+// @Failure 500 {object} utils.Response "Failed to cancel stock count"
+// @Router /inventory/counts/{id} [delete]
+func CancelStockCount(c *gin.Context) {
+	if !utils.IsAdmin(c) {
+		c.JSON(http.StatusForbidden, utils.GenerateResponse("failed", "You are not authorized to perform this action", nil, ""))
+		return
+	}
+
+	var count models.StockCount
+	if err := database.DB.Select("id").First(&count, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, utils.GenerateResponse("failed", "Stock count not found", nil, err.Error()))
+		return
+	}
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		count, err = services.CancelStockCount(tx, count.ID)
+		return err
+	})
+	if err != nil {
+		if errors.Is(err, services.ErrCountClosed) {
+			c.JSON(http.StatusConflict, utils.GenerateResponse("failed", "Stock count is not open", nil, err.Error()))
+			return
+		}
+		c.JSON(http.StatusInternalServerError, utils.GenerateResponse("failed", "Failed to cancel stock count", nil, err.Error()))
+		return
+	}
+
+	c.JSON(http.StatusOK, utils.GenerateResponse("success", "Stock count cancelled successfully", count, ""))
+}
+
+// countEntries reads uploaded counts from a JSON body or a CSV file, responding with 400 if they cannot be read
+func countEntries(c *gin.Context) ([]services.CountEntry, bool) {
+	if c.ContentType() == "text/csv" || strings.HasPrefix(c.ContentType(), "multipart/") {
+		body, _, err := importSource(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, utils.GenerateResponse("failed", "Invalid counts", nil, err.Error()))
+			return nil, false
+		}
+		defer body.Close()
+
+		entries, err := services.ReadCountCSV(body)
+		if err != nil {
+			var countErr *services.CountError
+			if errors.As(err, &countErr) {
+				c.JSON(http.StatusBadRequest, utils.GenerateResponse("failed", "Invalid counts", countErr.Problems, err.Error()))
+			} else {
+				c.JSON(http.StatusBadRequest, utils.GenerateResponse("failed", "Invalid counts", nil, err.Error()))
+			}
+			return nil, false
+		}
+		return entries, true
+	}
+
+	var input struct {
+		Lines []struct {
+			ProductID uint   `json:"product_id"`
+			SKU       string `json:"sku"`
+			Quantity  *int   `json:"quantity" binding:"required,gte=0"`
+		} `json:"lines" binding:"required,min=1,dive"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, utils.GenerateResponse("failed", "Invalid request data", utils.FieldErrors(err), err.Error()))
+		return nil, false
+	}
+
+	entries := make([]services.CountEntry, len(input.Lines))
+	for i, line := range input.Lines {
+		entries[i] = services.CountEntry{Row: i + 1, ProductID: line.ProductID, SKU: line.SKU, Quantity: *line.Quantity}
+	}
+	return entries, true
+}
@@ -0,0 +1,147 @@
+package controllers
+
+import (
+	"net/http"
+
+	"go-ecommerce-api/database"
+	"go-ecommerce-api/models"
+	"go-ecommerce-api/services"
+	"go-ecommerce-api/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// ListProductTranslations lists every translation of a product (admin only)
+// @Summary List product translations
+// @Description Get the translated names and descriptions of a product in every locale.
+// @Tags Translations
+// @Produce json
+// @Param id path int true "Product ID"
+// @Success 200 {object} utils.Response{data=[]models.ProductTranslation} "Translations retrieved successfully"
+// @Failure 403 {object} utils.Response "Unauthorized action"
+// @Failure 500 {object} utils.Response "Failed to fetch translations"
+// @Router /products/{id}/translations [get]
+func ListProductTranslations(c *gin.Context) {
+	if !utils.IsAdmin(c) {
+		c.JSON(http.StatusForbidden, utils.GenerateResponse("failed", "You are not authorized to perform this action", nil, ""))
+		return
+	}
+
+	var translations []models.ProductTranslation
+	if err := database.DB.Where("product_id = ?", c.Param("id")).Order("locale").Find(&translations).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, utils.GenerateResponse("failed", "Failed to fetch translations", nil, err.Error()))
+		return
+	}
+
+	c.JSON(http.StatusOK, utils.GenerateResponse("success", "Translations retrieved successfully", translations, ""))
+}
+
+// SetProductTranslation creates or replaces a product's translation for a locale (admin only)
+// @Summary Set a product translation
+// @Description Create or replace the name and description of a product in one locale.
+// @Description Leave the description empty to fall back to the next locale for it.
+// @Tags Translations
+// @Accept json
+// @Produce json
+// @Param id path int true "Product ID"
+// @Param locale path string true "BCP 47 locale, e.g. pt-BR"
+// @Param translation body object{name=string,description=string} true "Translated content"
+// @Success 200 {object} utils.Response{data=models.ProductTranslation} "Translation saved successfully"
+// @Failure 400 {object} utils.Response "Invalid request data"
+// @Failure 403 {object} utils.Response "Unauthorized action"
+// @Failure 404 {object} utils.Response "Product not found"
+// @Failure 500 {object} utils.Response "Failed to save translation"
+// @Router /products/{id}/translations/{locale} [put]
+func SetProductTranslation(c *gin.Context) {
+	if !utils.IsAdmin(c) {
+		c.JSON(http.StatusForbidden, utils.GenerateResponse("failed", "You are not authorized to perform this action", nil, ""))
+		return
+	}
+
+	locale, err := utils.ParseLocale(c.Param("locale"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, utils.GenerateResponse("failed", "Invalid locale", nil, err.Error()))
+		return
+	}
+
+	var input struct {
+		Name        string `json:"name" binding:"required,notblank,max=255"`
+		Description string `json:"description" binding:"max=5000"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, utils.GenerateResponse("failed", "Invalid request data", utils.FieldErrors(err), err.Error()))
+		return
+	}
+
+	var product models.Product
+	if err := database.DB.First(&product, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, utils.GenerateResponse("failed", "Product not found", nil, err.Error()))
+		return
+	}
+
+	translation := models.ProductTranslation{
+		ProductID:   product.ID,
+		Locale:      locale,
+		Name:        input.Name,
+		Description: input.Description,
+	}
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		return services.SetTranslation(tx, &translation)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, utils.GenerateResponse("failed", "Failed to save translation", nil, err.Error()))
+		return
+	}
+
+	c.JSON(http.StatusOK, utils.GenerateResponse("success", "Translation saved successfully", translation, ""))
+}
+
+// DeleteProductTranslation removes a product's translation for a locale (admin only)
+// @Summary Delete a product translation
+// @Description Remove a product's content in one locale; requests for it fall back along the locale chain.
+// @Tags Translations
+// @Produce json
+// @Param id path int true "Product ID"
+// @Param locale path string true "BCP 47 locale, e.g. pt-BR"
+// @Success 200 {object} utils.Response "Translation deleted successfully"
+// @Failure 400 {object} utils.Response "Invalid locale"
+// @Failure 403 {object} utils.Response "Unauthorized action"
+// @Failure 404 {object} utils.Response "Translation not found"
+// @Failure 500 {object} utils.Response "Failed to delete translation"
+// @Router /products/{id}/translations/{locale} [delete]
+func DeleteProductTranslation(c *gin.Context) {
+	if !utils.IsAdmin(c) {
+		c.JSON(http.StatusForbidden, utils.GenerateResponse("failed", "You are not authorized to perform this action", nil, ""))
+		return
+	}
+
+	locale, err := utils.ParseLocale(c.Param("locale"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, utils.GenerateResponse("failed", "Invalid locale", nil, err.Error()))
+		return
+	}
+
+	var product models.Product
+	if err := database.DB.Select("id").First(&product, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, utils.GenerateResponse("failed", "Product not found", nil, err.Error()))
+		return
+	}
+
+	var deleted bool
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		deleted, err = services.DeleteTranslation(tx, product.ID, locale)
+		return err
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, utils.GenerateResponse("failed", "Failed to delete translation", nil, err.Error()))
+		return
+	}
+	if !deleted {
+		c.JSON(http.StatusNotFound, utils.GenerateResponse("failed", "Translation not found", nil, ""))
+		return
+	}
+
+	c.JSON(http.StatusOK, utils.GenerateResponse("success", "Translation deleted successfully", nil, ""))
+}
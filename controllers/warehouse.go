@@ -0,0 +1,219 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+
+	"go-ecommerce-api/database"
+	"go-ecommerce-api/models"
+	"go-ecommerce-api/services"
+	"go-ecommerce-api/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// CreateWarehouse adds a stock location (admin only)
+// @Summary Create a warehouse
+// @Description Add a location stock is held and shipped from. Orders are fulfilled from lower priority values first.
+// @Tags Warehouses
+// @Accept json
+// @Produce json
+// @Param warehouse body object{code=string,name=string,priority=int} true "Warehouse"
+// @Success 201 {object} utils.Response{data=models.Warehouse} "Warehouse created successfully"
+// @Failure 400 {object} utils.Response "Invalid request data"
+// @Failure 403 {object} utils.Response "Unauthorized action"
+// @Failure 500 {object} utils.Response "Failed to create warehouse"
+// @Router /warehouses [post]
+func CreateWarehouse(c *gin.Context) {
+	if !utils.IsAdmin(c) {
+		c.JSON(http.StatusForbidden, utils.GenerateResponse("failed", "You are not authorized to perform this action", nil, ""))
+		return
+	}
+
+	var input struct {
+		Code     string `json:"code" binding:"required,sku,max=32"`
+		Name     string `json:"name" binding:"required,notblank,max=255"`
+		Priority *int   `json:"priority" binding:"omitempty,gte=0"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, utils.GenerateResponse("failed", "Invalid request data", utils.FieldErrors(err), err.Error()))
+		return
+	}
+
+	warehouse := models.Warehouse{Code: input.Code, Name: input.Name}
+	if input.Priority != nil {
+		warehouse.Priority = *input.Priority
+	}
+	if err := database.DB.Create(&warehouse).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, utils.GenerateResponse("failed", "Failed to create warehouse", nil, err.Error()))
+		return
+	}
+
+	c.JSON(http.StatusCreated, utils.GenerateResponse("success", "Warehouse created successfully", warehouse, ""))
+}
+
+// ListWarehouses lists the stock locations in fulfillment order (admin only)
+// @Summary List warehouses
+// @Description Get every warehouse, in the order orders are fulfilled from them.
+// @Tags Warehouses
+// @Produce json
+// @Success 200 {object} utils.Response{data=[]models.Warehouse} "Warehouses retrieved successfully"
+// @Failure 403 {object} utils.Response "Unauthorized action"
+// @Failure 500 {object} utils.Response "Failed to fetch warehouses"
+// @Router /warehouses [get]
+func ListWarehouses(c *gin.Context) {
+	if !utils.IsAdmin(c) {
+		c.JSON(http.StatusForbidden, utils.GenerateResponse("failed", "You are not authorized to perform this action", nil, ""))
+		return
+	}
+
+	var warehouses []models.Warehouse
+	if err := database.DB.Order("priority, id").Find(&warehouses).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, utils.GenerateResponse("failed", "Failed to fetch warehouses", nil, err.Error()))
+		return
+	}
+
+	c.JSON(http.StatusOK, utils.GenerateResponse("success", "Warehouses retrieved successfully", warehouses, ""))
+}
+
+// UpdateWarehouse renames a warehouse or changes its priority (admin only)
+// @Summary Update a warehouse
+// @Description Change a warehouse's name or fulfillment priority. The code cannot be changed.
+// @Tags Warehouses
+// @Accept json
+// @Produce json
+// @Param id path int true "Warehouse ID"
+// @Param warehouse body object{name=string,priority=int} true "Warehouse"
+// @Success 200 {object} utils.Response{data=models.Warehouse} "Warehouse updated successfully"
+// @Failure 400 {object} utils.Response "Invalid request data"
+// @Failure 403 {object} utils.Response "Unauthorized action"
+// @Failure 404 {object} utils.Response "Warehouse not found"
+// @Failure 500 {object} utils.Response "Failed to update warehouse"
+// @Router /warehouses/{id} [put]
+func UpdateWarehouse(c *gin.Context) {
+	if !utils.IsAdmin(c) {
+		c.JSON(http.StatusForbidden, utils.GenerateResponse("failed", "You are not authorized to perform this action", nil, ""))
+		return
+	}
+
+	var input struct {
+		Name     string `json:"name" binding:"required,notblank,max=255"`
+		Priority *int   `json:"priority" binding:"required,gte=0"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, utils.GenerateResponse("failed", "Invalid request data", utils.FieldErrors(err), err.Error()))
+		return
+	}
+
+	var warehouse models.Warehouse
+	if err := database.DB.First(&warehouse, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, utils.GenerateResponse("failed", "Warehouse not found", nil, err.Error()))
+		return
+	}
+
+	warehouse.Name = input.Name
+	warehouse.Priority = *input.Priority
+	if err := database.DB.Save(&warehouse).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, utils.GenerateResponse("failed", "Failed to update warehouse", nil, err.Error()))
+		return
+	}
+
+	c.JSON(http.StatusOK, utils.GenerateResponse("success", "Warehouse updated successfully", warehouse, ""))
+}
+
+// GetProductStock shows a product's stock at every warehouse (admin only)
+// @Summary Get product stock by warehouse
+// @Description Get a product's stock, reserved quantity and availability at each warehouse.
+// @Tags Warehouses
+// @Produce json
+// @Param id path int true "Product ID"
+// @Success 200 {object} utils.Response{data=[]services.WarehouseStock} "Stock retrieved successfully"
+// @Failure 403 {object} utils.Response "Unauthorized action"
+// @Failure 404 {object} utils.Response "Product not found"
+// @Failure 500 {object} utils.Response "Failed to fetch stock"
+// @Router /products/{id}/stock [get]
+func GetProductStock(c *gin.Context) {
+	if !utils.IsAdmin(c) {
+		c.JSON(http.StatusForbidden, utils.GenerateResponse("failed", "You are not authorized to perform this action", nil, ""))
+		return
+	}
+
+	var product models.Product
+	if err := database.DB.Select("id").First(&product, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, utils.GenerateResponse("failed", "Product not found", nil, err.Error()))
+		return
+	}
+
+	stock, err := services.ProductStockByWarehouse(database.DB, product.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, utils.GenerateResponse("failed", "Failed to fetch stock", nil, err.Error()))
+		return
+	}
+
+	c.JSON(http.StatusOK, utils.GenerateResponse("success", "Stock retrieved successfully", stock, ""))
+}
+
+// SetProductStockLevel sets a product's stock at one warehouse (admin only)
+// @Summary Set product stock at a warehouse
+// @Description Set how much of a product one warehouse holds. The product's total stock is updated to match.
+// @Description The quantity cannot be lowered below what pending orders have reserved at the warehouse.
+// @Tags Warehouses
+// @Accept json
+// @Produce json
+// @Param id path int true "Product ID"
+// @Param warehouse_id path int true "Warehouse ID"
+// @Param stock body object{quantity=int,note=string} true "Stock at the warehouse and an optional note for the inventory ledger"
+// @Success 200 {object} utils.Response{data=models.StockLevel} "Stock updated successfully"
+// @Failure 400 {object} utils.Response "Invalid request data"
+// @Failure 403 {object} utils.Response "Unauthorized action"
+// @Failure 404 {object} utils.Response "Product or warehouse not found"
+// @Failure 500 {object} utils.Response "Failed to update stock"
+// @Router /products/{id}/stock/{warehouse_id} [put]
+func SetProductStockLevel(c *gin.Context) {
+	if !utils.IsAdmin(c) {
+		c.JSON(http.StatusForbidden, utils.GenerateResponse("failed", "You are not authorized to perform this action", nil, ""))
+		return
+	}
+
+	var input struct {
+		Quantity *int   `json:"quantity" binding:"required,gte=0"`
+		Note     string `json:"note" binding:"max=500"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, utils.GenerateResponse("failed", "Invalid request data", utils.FieldErrors(err), err.Error()))
+		return
+	}
+
+	var warehouse models.Warehouse
+	if err := database.DB.First(&warehouse, c.Param("warehouse_id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, utils.GenerateResponse("failed", "Warehouse not found", nil, err.Error()))
+		return
+	}
+
+	var product models.Product
+	if err := database.DB.Select("id").First(&product, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, utils.GenerateResponse("failed", "Product not found", nil, err.Error()))
+		return
+	}
+
+	adminID := c.MustGet("userID").(uint)
+	var level models.StockLevel
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		change := services.StockChange{Reason: services.MovementAdjustment, ActorID: &adminID, Note: input.Note}
+		level, err = services.SetStockLevel(tx, product.ID, warehouse.ID, *input.Quantity, change)
+		return err
+	})
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidStockLevel) {
+			c.JSON(http.StatusBadRequest, utils.GenerateResponse("failed", "Invalid request data", nil, err.Error()))
+			return
+		}
+		c.JSON(http.StatusInternalServerError, utils.GenerateResponse("failed", "Failed to update stock", nil, err.Error()))
+		return
+	}
+
+	level.Warehouse = warehouse
+	c.JSON(http.StatusOK, utils.GenerateResponse("success", "Stock updated successfully", level, ""))
+}
@@ -0,0 +1,95 @@
+package controllers
+
+import (
+	"net/http"
+
+	"go-ecommerce-api/database"
+	"go-ecommerce-api/models"
+	"go-ecommerce-api/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetWishlist lists the products the authenticated user has saved
+// @Summary Get wishlist
+// @Description Fetch the authenticated user's wishlist with product details, most recently added first. Products no longer for sale are left out.
+// @Tags Wishlist
+// @Produce json
+// @Success 200 {object} utils.Response{data=[]models.WishlistItem} "Wishlist retrieved successfully"
+// @Failure 500 {object} utils.Response "Failed to retrieve wishlist"
+// @Security ApiKeyAuth
+// @Router /wishlist [get]
+func GetWishlist(c *gin.Context) {
+	var items []models.WishlistItem
+	visible := visibleProducts(c).Model(&models.Product{}).Select("id")
+	if err := database.DB.Preload("Product").Where("user_id = ? AND product_id IN (?)", c.MustGet("userID").(uint), visible).Order("created_at DESC").Find(&items).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, utils.GenerateResponse("failed", "Failed to retrieve wishlist", nil, err.Error()))
+		return
+	}
+
+	c.JSON(http.StatusOK, utils.GenerateResponse("success", "Wishlist retrieved successfully", items, ""))
+}
+
+// AddToWishlist saves a product to the authenticated user's wishlist
+// @Summary Add to wishlist
+// @Description Save a product for later. You will be notified when an out-of-stock wishlisted product can be bought again. Adding a product twice is a no-op.
+// @Tags Wishlist
+// @Accept json
+// @Produce json
+// @Param item body object{product_id=int} true "Product to save"
+// @Success 201 {object} utils.Response{data=models.WishlistItem} "Product added to wishlist"
+// @Failure 400 {object} utils.Response "Invalid request data"
+// @Failure 404 {object} utils.Response "Product not found"
+// @Failure 500 {object} utils.Response "Failed to update wishlist"
+// @Security ApiKeyAuth
+// @Router /wishlist [post]
+func AddToWishlist(c *gin.Context) {
+	var input struct {
+		ProductID uint `json:"product_id" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, utils.GenerateResponse("failed", "Invalid request data", utils.FieldErrors(err), err.Error()))
+		return
+	}
+
+	var product models.Product
+	if err := visibleProducts(c).First(&product, input.ProductID).Error; err != nil {
+		c.JSON(http.StatusNotFound, utils.GenerateResponse("failed", "Product not found", nil, err.Error()))
+		return
+	}
+
+	item := models.WishlistItem{UserID: c.MustGet("userID").(uint), ProductID: product.ID}
+	if err := database.DB.Where(item).FirstOrCreate(&item).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, utils.GenerateResponse("failed", "Failed to update wishlist", nil, err.Error()))
+		return
+	}
+	item.Product = product
+
+	c.JSON(http.StatusCreated, utils.GenerateResponse("success", "Product added to wishlist", item, ""))
+}
+
+// RemoveFromWishlist removes a product from the authenticated user's wishlist
+// @Summary Remove from wishlist
+// @Description Remove a saved product from the wishlist.
+// @Tags Wishlist
+// @Produce json
+// @Param product_id path int true "Product ID"
+// @Success 200 {object} utils.Response "Product removed from wishlist"
+// @Failure 404 {object} utils.Response "Product is not in your wishlist"
+// @Failure 500 {object} utils.Response "Failed to update wishlist"
+// @Security ApiKeyAuth
+// @Router /wishlist/{product_id} [delete]
+func RemoveFromWishlist(c *gin.Context) {
+	result := database.DB.Where("user_id = ? AND product_id = ?", c.MustGet("userID").(uint), c.Param("product_id")).Delete(&models.WishlistItem{})
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, utils.GenerateResponse("failed", "Failed to update wishlist", nil, result.Error.Error()))
+		return
+	}
+
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, utils.GenerateResponse("failed", "Product is not in your wishlist", nil, ""))
+		return
+	}
+
+	c.JSON(http.StatusOK, utils.GenerateResponse("success", "Product removed from wishlist", nil, ""))
+}
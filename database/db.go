@@ -33,10 +33,75 @@ func ConnectToDatabase() {
 	log.Println("Database connection established successfully!")
 
 	// Run migrations
-	err = DB.AutoMigrate(&models.User{}, &models.Product{}, &models.Order{}, &models.OrderItem{})
+	err = DB.AutoMigrate(
+		&models.User{}, &models.Product{}, &models.Order{}, &models.OrderItem{}, &models.OrderEvent{},
+		&models.ProductImportJob{}, &models.ProductImportError{},
+		&models.PriceChange{}, &models.PriceSchedule{},
+		&models.AttributeDefinition{},
+		&models.ProductAffinity{}, &models.RecommendationOverride{},
+		&models.Review{},
+		&models.WishlistItem{}, &models.Notification{},
+		&models.ProductSlugRedirect{},
+		&models.ProductFile{}, &models.DownloadGrant{},
+		&models.BundleComponent{},
+		&models.ProductTranslation{},
+		&models.Warehouse{}, &models.StockLevel{}, &models.StockReservation{},
+		&models.InventoryMovement{}, &models.LowStockEvent{},
+		&models.StockCount{}, &models.StockCountLine{},
+		&models.IdempotencyKey{},
+	)
 	if err != nil {
 		log.Fatalf("Failed to run migrations: %v", err)
 	}
+	if err := migrateForeignKeyRules(); err != nil {
+		log.Fatalf("Failed to run migrations: %v", err)
+	}
 
 	log.Println("Database migrations ran successfully!")
 }
+
+// foreignKeyRules lists foreign keys whose ON DELETE rule changed after they were first created.
+// AutoMigrate only creates missing constraints, so existing ones are recreated with the new rule.
+var foreignKeyRules = []struct {
+	model interface{}
+	field string // Relationship the constraint is declared on
+	rule  string // Expected pg_constraint.confdeltype: r is RESTRICT, n is SET NULL
+}{
+	{&models.BundleComponent{}, "Component", "r"},
+	{&models.OrderItem{}, "Product", "n"},
+}
+
+func migrateForeignKeyRules() error {
+	for _, fk := range foreignKeyRules {
+		stmt := &gorm.Statement{DB: DB}
+		if err := stmt.Parse(fk.model); err != nil {
+			return err
+		}
+		constraint := stmt.Schema.Relationships.Relations[fk.field].ParseConstraint()
+		if constraint == nil {
+			return fmt.Errorf("%s.%s has no foreign key constraint", stmt.Schema.Name, fk.field)
+		}
+
+		var rule string
+		err := DB.Raw("SELECT confdeltype::text FROM pg_constraint WHERE conname = ? AND conrelid = ?::regclass", constraint.Name, stmt.Schema.Table).
+			Scan(&rule).Error
+		if err != nil {
+			return err
+		}
+		if rule == "" || rule == fk.rule {
+			continue
+		}
+
+		err = DB.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Migrator().DropConstraint(fk.model, constraint.Name); err != nil {
+				return err
+			}
+			return tx.Migrator().CreateConstraint(fk.model, constraint.Name)
+		})
+		if err != nil {
+			return fmt.Errorf("recreating %s: %w", constraint.Name, err)
+		}
+		log.Printf("Changed the delete rule of %s", constraint.Name)
+	}
+	return nil
+}
@@ -0,0 +1,32 @@
+package jobs
+
+import (
+	"time"
+
+	"go-ecommerce-api/services"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Start launches the periodic background jobs. It returns immediately.
+func Start() {
+	go every("price-schedules", time.Minute, services.ApplyDuePriceSchedules)
+	go every("product-lifecycles", time.Minute, services.ApplyProductLifecycles)
+	go every("product-affinities", time.Hour, services.RefreshProductAffinities)
+	go every("notifications", 30*time.Second, services.DeliverQueuedNotifications)
+	go every("stock-reservations", time.Minute, services.ReleaseExpiredReservations)
+	go every("idempotency-keys", time.Hour, services.PurgeExpiredIdempotencyKeys)
+}
+
+// every runs fn immediately and then on a fixed interval for the lifetime of the process
+func every(name string, interval time.Duration, fn func() error) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := fn(); err != nil {
+			logrus.WithError(err).WithField("job", name).Error("Background job failed")
+		}
+		<-ticker.C
+	}
+}
@@ -2,7 +2,10 @@ package main
 
 import (
 	"go-ecommerce-api/database"
+	"go-ecommerce-api/jobs"
 	"go-ecommerce-api/routes"
+	"go-ecommerce-api/services"
+	"go-ecommerce-api/utils"
 	"log"
 
 	"github.com/gin-gonic/gin"
@@ -36,9 +39,28 @@ func main() {
 
 	// Connect to the database
 	database.ConnectToDatabase()
+	if err := services.BackfillProductSlugs(); err != nil {
+		log.Fatalf("Failed to backfill product slugs: %v", err)
+	}
+	if err := services.EnsureDefaultWarehouse(); err != nil {
+		log.Fatalf("Failed to set up the default warehouse: %v", err)
+	}
+	if err := services.BackfillInventoryLedger(); err != nil {
+		log.Fatalf("Failed to backfill the inventory ledger: %v", err)
+	}
+	if err := services.NormalizeOrderStatuses(); err != nil {
+		log.Fatalf("Failed to normalize order statuses: %v", err)
+	}
+	if err := services.BackfillOrderItemSnapshots(); err != nil {
+		log.Fatalf("Failed to backfill order item snapshots: %v", err)
+	}
+
+	// Start background jobs such as scheduled price changes
+	jobs.Start()
 
-	// Set up Gin router and routes
+	// Set up Gin router, custom validators and routes
 	gin.SetMode(gin.DebugMode)
+	utils.RegisterValidators()
 	router := routes.SetupRoutes()
 	router.SetTrustedProxies(nil)
 
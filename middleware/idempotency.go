@@ -0,0 +1,117 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+
+	"go-ecommerce-api/services"
+	"go-ecommerce-api/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// maxIdempotencyKeyLength matches the size of the column keys are stored in
+const maxIdempotencyKeyLength = 255
+
+// IdempotencyMiddleware makes unsafe requests that carry an Idempotency-Key header safe to retry.
+// The first request with a key runs normally and its response is stored; a retry with the same
+// key and the same method, path and body gets the stored response back, with its ETag and Location
+// headers, without running again, marked with an Idempotent-Replayed header. Reusing a key for a different request is rejected.
+// Keys are scoped to the user, so it must run after JWTMiddleware or CustomerJWTMiddleware.
+func IdempotencyMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader("Idempotency-Key")
+		if key == "" || c.Request.Method == http.MethodGet || c.Request.Method == http.MethodHead || c.Request.Method == http.MethodOptions {
+			c.Next()
+			return
+		}
+		if len(key) > maxIdempotencyKeyLength {
+			c.AbortWithStatusJSON(http.StatusBadRequest, utils.GenerateResponse("failed", "Invalid Idempotency-Key", nil, "Idempotency-Key must be at most 255 characters"))
+			return
+		}
+
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, utils.GenerateResponse("failed", "Failed to read request body", nil, err.Error()))
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		hash := sha256.New()
+		hash.Write([]byte(c.Request.Method + " " + c.Request.URL.RequestURI() + "\n"))
+		hash.Write(body)
+		fingerprint := hex.EncodeToString(hash.Sum(nil))
+
+		record, claimed, err := services.ClaimIdempotencyKey(c.MustGet("userID").(uint), key, fingerprint)
+		switch {
+		case errors.Is(err, services.ErrIdempotencyKeyReused):
+			c.AbortWithStatusJSON(http.StatusUnprocessableEntity, utils.GenerateResponse("failed", "Idempotency-Key was already used for a different request", nil, err.Error()))
+			return
+		case errors.Is(err, services.ErrIdempotencyKeyInFlight):
+			c.AbortWithStatusJSON(http.StatusConflict, utils.GenerateResponse("failed", "A request with this Idempotency-Key is still being processed", nil, err.Error()))
+			return
+		case err != nil:
+			c.AbortWithStatusJSON(http.StatusInternalServerError, utils.GenerateResponse("failed", "Failed to check Idempotency-Key", nil, err.Error()))
+			return
+		case !claimed:
+			c.Header("Idempotent-Replayed", "true")
+			if record.ETag != "" {
+				c.Header("ETag", record.ETag)
+			}
+			if record.Location != "" {
+				c.Header("Location", record.Location)
+			}
+			c.Data(record.ResponseCode, record.ContentType, record.ResponseBody)
+			c.Abort()
+			return
+		}
+
+		// A panicking handler unwinds past the code after c.Next, so the key is released here
+		// instead of staying claimed; the panic carries on to the recovery middleware
+		finished := false
+		defer func() {
+			if finished {
+				return
+			}
+			if err := services.ReleaseIdempotencyKey(record); err != nil {
+				logrus.WithError(err).WithField("key", key).Error("Failed to release idempotency key")
+			}
+		}()
+
+		recorder := &responseRecorder{ResponseWriter: c.Writer}
+		c.Writer = recorder
+		c.Next()
+		finished = true
+
+		// Server errors are not stored, so the client can retry once the problem is fixed
+		if recorder.Status() >= http.StatusInternalServerError {
+			err = services.ReleaseIdempotencyKey(record)
+		} else {
+			err = services.CompleteIdempotencyKey(record, recorder.Status(), recorder.Header(), recorder.body.Bytes())
+		}
+		if err != nil {
+			logrus.WithError(err).WithField("key", key).Error("Failed to store idempotent response")
+		}
+	}
+}
+
+// responseRecorder keeps a copy of the response body as it is written
+type responseRecorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *responseRecorder) Write(data []byte) (int, error) {
+	w.body.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *responseRecorder) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
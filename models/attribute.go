@@ -0,0 +1,17 @@
+package models
+
+import (
+	"time"
+)
+
+// AttributeDefinition describes a custom product attribute and how its values are validated
+type AttributeDefinition struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	Key       string    `gorm:"type:varchar(64);uniqueIndex;not null" json:"key"` // Name used in Product.Attributes
+	Label     string    `gorm:"not null" json:"label"`
+	Type      string    `gorm:"type:varchar(20);not null" json:"type"`               // 'string', 'number', 'enum' or 'boolean'
+	Options   []string  `gorm:"type:jsonb;serializer:json" json:"options,omitempty"` // Allowed values for enum attributes
+	Required  bool      `gorm:"not null;default:false" json:"required"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
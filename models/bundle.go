@@ -0,0 +1,10 @@
+package models
+
+// BundleComponent is one product included in a bundle, with how many units of it one bundle contains
+type BundleComponent struct {
+	ID          uint    `gorm:"primaryKey" json:"id"`
+	BundleID    uint    `gorm:"not null;uniqueIndex:idx_bundle_components_bundle_component" json:"bundle_id"`
+	ComponentID uint    `gorm:"not null;uniqueIndex:idx_bundle_components_bundle_component;index" json:"component_id"`
+	Component   Product `gorm:"foreignKey:ComponentID;constraint:OnDelete:RESTRICT" json:"component"` // A product cannot be deleted while bundles contain it
+	Quantity    int     `gorm:"not null;default:1" json:"quantity"`
+}
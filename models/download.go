@@ -0,0 +1,33 @@
+package models
+
+import (
+	"time"
+)
+
+// ProductFile is a downloadable file attached to a digital product
+type ProductFile struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	ProductID   uint      `gorm:"not null;index" json:"product_id"`
+	Product     Product   `gorm:"foreignKey:ProductID;constraint:OnDelete:CASCADE" json:"-"`
+	FileName    string    `gorm:"not null" json:"file_name"` // Name offered to the buyer when downloading
+	StoragePath string    `gorm:"not null" json:"-"`         // Location in local storage
+	ContentType string    `json:"content_type"`
+	Size        int64     `json:"size"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// DownloadGrant entitles the buyer of a digital product to download one of its files
+// a limited number of times until it expires
+type DownloadGrant struct {
+	ID            uint        `gorm:"primaryKey" json:"id"`
+	OrderID       uint        `gorm:"not null;uniqueIndex:idx_download_grants_order_file" json:"order_id"`
+	Order         Order       `gorm:"foreignKey:OrderID;constraint:OnDelete:CASCADE" json:"-"`
+	ProductFileID uint        `gorm:"not null;uniqueIndex:idx_download_grants_order_file" json:"product_file_id"`
+	ProductFile   ProductFile `gorm:"foreignKey:ProductFileID;constraint:OnDelete:CASCADE" json:"file"`
+	UserID        uint        `gorm:"not null;index" json:"user_id"`
+	Token         string      `gorm:"type:varchar(64);uniqueIndex;not null" json:"-"`
+	DownloadCount int         `gorm:"not null;default:0" json:"download_count"`
+	MaxDownloads  int         `gorm:"not null" json:"max_downloads"`
+	ExpiresAt     time.Time   `gorm:"not null" json:"expires_at"`
+	CreatedAt     time.Time   `json:"created_at"`
+}
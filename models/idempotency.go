@@ -0,0 +1,23 @@
+package models
+
+import (
+	"time"
+)
+
+// IdempotencyKey remembers a request made with an Idempotency-Key header and the response it got,
+// so that a retry of the request is answered with the same response instead of being run again
+type IdempotencyKey struct {
+	ID           uint      `gorm:"primaryKey" json:"id"`
+	UserID       uint      `gorm:"not null;uniqueIndex:idx_idempotency_keys_user_key" json:"user_id"`
+	Key          string    `gorm:"type:varchar(255);not null;uniqueIndex:idx_idempotency_keys_user_key" json:"key"` // Chosen by the client, unique per user
+	Fingerprint  string    `gorm:"type:varchar(64);not null" json:"fingerprint"`                                    // SHA-256 of the method, path and body
+	Status       string    `gorm:"type:varchar(20);not null;default:'processing'" json:"status"`                    // processing or completed
+	Attempt      int       `gorm:"not null;default:1" json:"attempt"`                                               // Goes up each time a retry takes over an abandoned key
+	ResponseCode int       `json:"response_code"`
+	ContentType  string    `json:"content_type"`
+	ETag         string    `gorm:"column:etag" json:"etag"`
+	Location     string    `json:"location"`
+	ResponseBody []byte    `json:"-"`
+	ExpiresAt    time.Time `gorm:"not null;index" json:"expires_at"`
+	CreatedAt    time.Time `json:"created_at"`
+}
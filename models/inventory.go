@@ -0,0 +1,32 @@
+package models
+
+import (
+	"time"
+)
+
+// InventoryMovement is one entry of the append-only inventory ledger. The stock of a product at a
+// warehouse is the sum of the deltas of its movements.
+type InventoryMovement struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	ProductID   uint      `gorm:"not null;index:idx_inventory_movements_product_warehouse" json:"product_id"`
+	Product     Product   `gorm:"foreignKey:ProductID;constraint:OnDelete:CASCADE" json:"-"`
+	WarehouseID uint      `gorm:"not null;index:idx_inventory_movements_product_warehouse" json:"warehouse_id"`
+	Warehouse   Warehouse `gorm:"foreignKey:WarehouseID;constraint:OnDelete:RESTRICT" json:"-"`
+	Reason      string    `gorm:"type:varchar(20);not null;index" json:"reason"` // sale, cancellation, adjustment, return or import
+	Delta       int       `gorm:"not null" json:"delta"`
+	ActorID     *uint     `json:"actor_id"` // User who caused the movement; nil for the system
+	OrderID     *uint     `gorm:"index" json:"order_id,omitempty"`
+	Reference   string    `gorm:"type:varchar(100)" json:"reference,omitempty"` // Free-form source, e.g. "import job 12"
+	Note        string    `json:"note,omitempty"`
+	CreatedAt   time.Time `gorm:"index" json:"created_at"`
+}
+
+// LowStockEvent records a product's stock falling below its reorder threshold
+type LowStockEvent struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	ProductID uint      `gorm:"not null;index" json:"product_id"`
+	Product   Product   `gorm:"foreignKey:ProductID;constraint:OnDelete:CASCADE" json:"-"`
+	Stock     int       `gorm:"not null" json:"stock"`
+	Threshold int       `gorm:"not null" json:"threshold"`
+	CreatedAt time.Time `json:"created_at"`
+}
@@ -0,0 +1,20 @@
+package models
+
+import (
+	"time"
+)
+
+// Notification is a message queued for delivery to a user through the notification channels
+type Notification struct {
+	ID        uint       `gorm:"primaryKey" json:"id"`
+	UserID    uint       `gorm:"not null;index" json:"user_id"`
+	User      User       `gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE" json:"-"`
+	Type      string     `gorm:"type:varchar(30);not null" json:"type"` // e.g. 'back_in_stock'
+	Subject   string     `gorm:"not null" json:"subject"`
+	Message   string     `gorm:"type:text;not null" json:"message"`
+	Status    string     `gorm:"type:varchar(20);default:'queued';index" json:"status"` // 'queued', 'sent' or 'failed'
+	Attempts  int        `gorm:"not null;default:0" json:"-"`
+	LastError string     `json:"-"`
+	CreatedAt time.Time  `json:"created_at"`
+	SentAt    *time.Time `json:"sent_at"`
+}
@@ -6,22 +6,51 @@ import (
 
 // Order represents a purchase transaction
 type Order struct {
-	ID          uint        `gorm:"primaryKey" json:"id"`
-	UserID      uint        `gorm:"not null" json:"user_id"`
-	User        User        `gorm:"foreignKey:UserID" json:"-"`
-	Status      string      `gorm:"type:varchar(20);default:'Pending'" json:"status"` // 'Pending', 'Completed', 'Cancelled'
-	TotalAmount float64     `gorm:"not null" json:"total_amount"`
-	CreatedAt   time.Time   `json:"created_at"`
-	OrderItems  []OrderItem `gorm:"foreignKey:OrderID" json:"order_items"`
+	ID          uint         `gorm:"primaryKey" json:"id"`
+	UserID      uint         `gorm:"not null" json:"user_id"`
+	User        User         `gorm:"foreignKey:UserID" json:"-"`
+	Status      string       `gorm:"type:varchar(20);default:'pending'" json:"status"` // pending, paid, processing, shipped, delivered, completed, cancelled or refunded
+	TotalAmount float64      `gorm:"not null" json:"total_amount"`
+	Version     uint         `gorm:"not null;default:1" json:"version"` // Incremented on every write, used for ETags
+	CreatedAt   time.Time    `json:"created_at"`
+	OrderItems  []OrderItem  `gorm:"foreignKey:OrderID" json:"order_items"`
+	Events      []OrderEvent `gorm:"foreignKey:OrderID;constraint:OnDelete:CASCADE" json:"events,omitempty"` // Status history, oldest first
 }
 
-// OrderItem represents a single product within an order
+// OrderEvent records one change of an order's status. The first event of an order, from no
+// status to pending, is its placement.
+type OrderEvent struct {
+	ID         uint      `gorm:"primaryKey" json:"id"`
+	OrderID    uint      `gorm:"not null;index" json:"order_id"`
+	FromStatus string    `gorm:"type:varchar(20)" json:"from_status"`
+	ToStatus   string    `gorm:"type:varchar(20);not null" json:"to_status"`
+	ActorID    *uint     `json:"actor_id"`                                    // User who made the change; nil for the system
+	ActorRole  string    `gorm:"type:varchar(20);not null" json:"actor_role"` // admin, customer or system
+	Note       string    `json:"note,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// OrderItem represents a single product within an order.
+// A bundle line has one child line per component, linked through BundleItemID; child lines
+// carry the share of the bundle price allocated to the component and are not part of the total.
+// Lines that cannot ship straight away are marked with Backordered or PreOrder. The product's
+// name, SKU and attributes are copied onto the line so it keeps its meaning if the product changes
+// or is deleted.
 type OrderItem struct {
-	ID        uint    `gorm:"primaryKey" json:"id"`
-	OrderID   uint    `gorm:"not null" json:"order_id"`
-	Order     Order   `gorm:"foreignKey:OrderID" json:"-"`
-	ProductID uint    `gorm:"not null" json:"product_id"`
-	Product   Product `gorm:"foreignKey:ProductID" json:"-"`
-	Quantity  int     `gorm:"not null" json:"quantity"`
-	Price     float64 `gorm:"not null" json:"price"` // Price of the product at the time of purchase
+	ID                uint                   `gorm:"primaryKey" json:"id"`
+	OrderID           uint                   `gorm:"not null" json:"order_id"`
+	Order             Order                  `gorm:"foreignKey:OrderID" json:"-"`
+	ProductID         *uint                  `gorm:"index" json:"product_id"` // Cleared when the product is deleted; the snapshot below remains
+	Product           Product                `gorm:"foreignKey:ProductID;constraint:OnDelete:SET NULL" json:"-"`
+	ProductName       string                 `gorm:"not null;default:''" json:"product_name"`
+	ProductSKU        string                 `gorm:"type:varchar(64);not null;default:''" json:"product_sku"`
+	ProductAttributes map[string]interface{} `gorm:"type:jsonb;serializer:json" json:"product_attributes,omitempty"`
+	Quantity          int                    `gorm:"not null" json:"quantity"`
+	Price             float64                `gorm:"not null" json:"price"`                               // Price of the product at the time of purchase
+	BundleItemID      *uint                  `gorm:"index" json:"bundle_item_id,omitempty"`               // Set on component lines of a bundle
+	Allocation        float64                `gorm:"not null;default:0" json:"allocation,omitempty"`      // Component lines: share of one bundle's price
+	Components        []OrderItem            `gorm:"foreignKey:BundleItemID" json:"components,omitempty"` // Bundle lines: the allocated components
+	Backordered       int                    `gorm:"not null;default:0" json:"backordered,omitempty"`     // Units that were out of stock when ordered and ship once restocked
+	PreOrder          bool                   `gorm:"not null;default:false" json:"pre_order,omitempty"`   // Ordered before the product launched; ships once it has
+	ExpectedAt        *time.Time             `json:"expected_at,omitempty"`                               // When held back units are expected to ship
 }
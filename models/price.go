@@ -0,0 +1,33 @@
+package models
+
+import (
+	"time"
+)
+
+// PriceChange records a single change to a product's price
+type PriceChange struct {
+	ID         uint      `gorm:"primaryKey" json:"id"`
+	ProductID  uint      `gorm:"not null;index" json:"product_id"`
+	Product    Product   `gorm:"foreignKey:ProductID;constraint:OnDelete:CASCADE" json:"-"`
+	OldPrice   float64   `gorm:"not null" json:"old_price"`
+	NewPrice   float64   `gorm:"not null" json:"new_price"`
+	Source     string    `gorm:"type:varchar(20);not null" json:"source"` // 'manual', 'import' or 'schedule'
+	ChangedBy  *uint     `json:"changed_by"`                              // Admin who made the change, nil for the scheduler
+	ScheduleID *uint     `json:"schedule_id,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// PriceSchedule is a future price for a product, such as a sale with a start and end time
+type PriceSchedule struct {
+	ID            uint       `gorm:"primaryKey" json:"id"`
+	ProductID     uint       `gorm:"not null;index" json:"product_id"`
+	Product       Product    `gorm:"foreignKey:ProductID;constraint:OnDelete:CASCADE" json:"-"`
+	Price         float64    `gorm:"not null" json:"price"`
+	StartsAt      time.Time  `gorm:"not null;index" json:"starts_at"`
+	EndsAt        *time.Time `json:"ends_at"`                                            // Optional; the previous price is restored when it passes
+	PreviousPrice *float64   `json:"previous_price,omitempty"`                           // Price replaced when the schedule started
+	Status        string     `gorm:"type:varchar(20);default:'scheduled'" json:"status"` // 'scheduled', 'active', 'completed', 'cancelled'
+	CreatedBy     uint       `gorm:"not null" json:"created_by"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
@@ -6,11 +6,56 @@ import (
 
 // Product represents an item available for purchase
 type Product struct {
-	ID          uint      `gorm:"primaryKey" json:"id"`
-	Name        string    `gorm:"not null" json:"name"`
-	Description string    `json:"description"`
-	Price       float64   `gorm:"not null" json:"price"`
-	Stock       int       `gorm:"not null" json:"stock"`
-	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"`
+	ID               uint                   `gorm:"primaryKey" json:"id"`
+	SKU              string                 `gorm:"type:varchar(64);uniqueIndex:idx_products_sku,where:sku <> ''" json:"sku"`
+	Name             string                 `gorm:"not null" json:"name"`
+	Slug             string                 `gorm:"type:varchar(160);uniqueIndex:idx_products_slug,where:slug <> ''" json:"slug"` // Derived from Name, stable until the name changes
+	Description      string                 `json:"description"`
+	Status           string                 `gorm:"type:varchar(20);not null;default:'published';index" json:"status"` // draft, scheduled, published or unpublished; only published products and pre-orders are sold
+	PublishAt        *time.Time             `json:"publish_at"`                                                        // When a scheduled product goes live
+	UnpublishAt      *time.Time             `json:"unpublish_at"`                                                      // When a published product is taken down
+	Price            float64                `gorm:"not null" json:"price"`
+	Stock            int                    `gorm:"not null" json:"stock"`                                            // Negative while more has been backordered than is on hand
+	ReorderThreshold int                    `gorm:"not null;default:0" json:"reorder_threshold"`                      // Admins are alerted when Stock falls below it; 0 disables alerts
+	BackorderPolicy  string                 `gorm:"type:varchar(20);not null;default:'none'" json:"backorder_policy"` // none, backorder or preorder: whether the product can be ordered beyond its stock
+	BackorderLimit   int                    `gorm:"not null;default:0" json:"backorder_limit"`                        // Units that may be ordered beyond stock; 0 means no limit
+	ExpectedAt       *time.Time             `json:"expected_at"`                                                      // When backordered or pre-ordered units are expected to ship
+	IsDigital        bool                   `gorm:"not null;default:false" json:"is_digital"`                         // Delivered as downloadable files instead of from stock
+	DownloadLimit    int                    `gorm:"not null;default:5" json:"download_limit"`                         // Downloads allowed per file and purchase for digital products
+	IsBundle         bool                   `gorm:"not null;default:false" json:"is_bundle"`                          // Sold as a set of component products, see Components
+	Components       []BundleComponent      `gorm:"foreignKey:BundleID;constraint:OnDelete:CASCADE" json:"components,omitempty"`
+	Available        *int                   `gorm:"-" json:"available,omitempty"`                           // Units that can be sold now; nil when unlimited
+	Locale           string                 `gorm:"-" json:"locale,omitempty"`                              // Locale Name is given in, set when content is localized
+	Attributes       map[string]interface{} `gorm:"type:jsonb;serializer:json" json:"attributes,omitempty"` // Values keyed by AttributeDefinition.Key
+	RatingAverage    float64                `gorm:"not null;default:0" json:"rating_average"`               // Cached from approved reviews
+	RatingCount      int                    `gorm:"not null;default:0" json:"rating_count"`                 // Cached from approved reviews
+	Version          uint                   `gorm:"not null;default:1" json:"version"`                      // Incremented on every write, used for ETags
+	CreatedAt        time.Time              `json:"created_at"`
+	UpdatedAt        time.Time              `json:"updated_at"`
+}
+
+// TracksStock reports whether selling the product consumes its own stock. Digital products never
+// run out, and bundles consume the stock of their components instead.
+func (p Product) TracksStock() bool {
+	return !p.IsDigital && !p.IsBundle
+}
+
+// ProductInput represents the product fields an admin may set on create or update.
+// Server-managed fields such as id, version and timestamps are deliberately absent.
+type ProductInput struct {
+	SKU              string                 `json:"sku" binding:"omitempty,sku"`
+	Name             string                 `json:"name" binding:"required,notblank,max=255"`
+	Description      string                 `json:"description" binding:"max=5000"`
+	Price            *float64               `json:"price" binding:"required,gte=0,price"`
+	Stock            *int                   `json:"stock" binding:"omitempty,gte=0"` // Required on create; left out on update to keep the current stock
+	ReorderThreshold int                    `json:"reorder_threshold" binding:"gte=0"`
+	BackorderPolicy  string                 `json:"backorder_policy" binding:"omitempty,oneof=none backorder preorder"` // New products default to none, updates keep the current policy
+	BackorderLimit   int                    `json:"backorder_limit" binding:"gte=0"`
+	ExpectedAt       *time.Time             `json:"expected_at"`
+	Status           string                 `json:"status" binding:"omitempty,oneof=draft scheduled published unpublished"` // New products default to draft, updates keep the current status
+	PublishAt        *time.Time             `json:"publish_at"`
+	UnpublishAt      *time.Time             `json:"unpublish_at"`
+	IsDigital        bool                   `json:"is_digital"`
+	DownloadLimit    int                    `json:"download_limit" binding:"omitempty,gte=1,lte=100"` // Defaults to 5
+	Attributes       map[string]interface{} `json:"attributes"`                                       // Left out on update to keep the product's attributes
 }
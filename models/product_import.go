@@ -0,0 +1,34 @@
+package models
+
+import (
+	"time"
+)
+
+// ProductImportJob tracks a bulk product import and its progress
+type ProductImportJob struct {
+	ID             uint                 `gorm:"primaryKey" json:"id"`
+	Format         string               `gorm:"type:varchar(10);not null" json:"format"` // 'csv' or 'ndjson'
+	DryRun         bool                 `gorm:"not null;default:false" json:"dry_run"`
+	Status         string               `gorm:"type:varchar(20);default:'queued'" json:"status"` // 'queued', 'running', 'completed', 'failed'
+	TotalBytes     int64                `json:"total_bytes"`
+	ProcessedBytes int64                `json:"processed_bytes"`
+	ProcessedRows  int                  `json:"processed_rows"`
+	CreatedRows    int                  `json:"created_rows"`
+	UpdatedRows    int                  `json:"updated_rows"`
+	FailedRows     int                  `json:"failed_rows"`
+	Error          string               `json:"error,omitempty"` // Fatal error that stopped the import
+	CreatedBy      uint                 `gorm:"not null" json:"created_by"`
+	CreatedAt      time.Time            `json:"created_at"`
+	UpdatedAt      time.Time            `json:"updated_at"`
+	FinishedAt     *time.Time           `json:"finished_at"`
+	Errors         []ProductImportError `gorm:"foreignKey:JobID" json:"errors,omitempty"`
+}
+
+// ProductImportError records why a single row of an import was rejected
+type ProductImportError struct {
+	ID      uint   `gorm:"primaryKey" json:"-"`
+	JobID   uint   `gorm:"not null;index" json:"-"`
+	Row     int    `gorm:"not null" json:"row"` // 1-based data row number, excluding the CSV header
+	SKU     string `json:"sku,omitempty"`
+	Message string `gorm:"not null" json:"message"`
+}
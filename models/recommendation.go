@@ -0,0 +1,28 @@
+package models
+
+import (
+	"time"
+)
+
+// ProductAffinity records how often two products were bought in the same order
+type ProductAffinity struct {
+	ProductID        uint      `gorm:"primaryKey" json:"product_id"`
+	Product          Product   `gorm:"foreignKey:ProductID;constraint:OnDelete:CASCADE" json:"-"`
+	RelatedProductID uint      `gorm:"primaryKey" json:"related_product_id"`
+	RelatedProduct   Product   `gorm:"foreignKey:RelatedProductID;constraint:OnDelete:CASCADE" json:"-"`
+	Score            int       `gorm:"not null" json:"score"` // Number of orders containing both products
+	UpdatedAt        time.Time `json:"updated_at"`
+}
+
+// RecommendationOverride lets an admin pin or exclude a specific recommendation
+type RecommendationOverride struct {
+	ID               uint      `gorm:"primaryKey" json:"id"`
+	ProductID        uint      `gorm:"not null;uniqueIndex:idx_recommendation_override" json:"product_id"`
+	Product          Product   `gorm:"foreignKey:ProductID;constraint:OnDelete:CASCADE" json:"-"`
+	RelatedProductID uint      `gorm:"not null;uniqueIndex:idx_recommendation_override" json:"related_product_id"`
+	RelatedProduct   Product   `gorm:"foreignKey:RelatedProductID;constraint:OnDelete:CASCADE" json:"-"`
+	Action           string    `gorm:"type:varchar(10);not null" json:"action"` // 'pin' or 'exclude'
+	Position         int       `gorm:"not null;default:0" json:"position"`      // Order of pinned recommendations, lowest first
+	CreatedBy        uint      `gorm:"not null" json:"created_by"`
+	CreatedAt        time.Time `json:"created_at"`
+}
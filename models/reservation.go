@@ -0,0 +1,21 @@
+package models
+
+import (
+	"time"
+)
+
+// StockReservation holds stock of a product for a pending order until it is paid, cancelled or expires
+type StockReservation struct {
+	ID          uint       `gorm:"primaryKey" json:"id"`
+	OrderID     uint       `gorm:"not null;index" json:"order_id"`
+	Order       Order      `gorm:"foreignKey:OrderID;constraint:OnDelete:CASCADE" json:"-"`
+	ProductID   uint       `gorm:"not null;index:idx_stock_reservations_product_status" json:"product_id"`
+	Product     Product    `gorm:"foreignKey:ProductID;constraint:OnDelete:CASCADE" json:"-"`
+	WarehouseID *uint      `gorm:"index" json:"warehouse_id"` // Location the stock is held at; nil for holds made before warehouses existed
+	Warehouse   *Warehouse `gorm:"foreignKey:WarehouseID;constraint:OnDelete:RESTRICT" json:"-"`
+	Quantity    int        `gorm:"not null" json:"quantity"`
+	Status      string     `gorm:"type:varchar(20);not null;default:'active';index:idx_stock_reservations_product_status" json:"status"` // active, committed or released
+	ExpiresAt   time.Time  `gorm:"not null;index" json:"expires_at"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
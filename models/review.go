@@ -0,0 +1,23 @@
+package models
+
+import (
+	"time"
+)
+
+// Review is a customer's star rating and write-up of a product they bought
+type Review struct {
+	ID             uint       `gorm:"primaryKey" json:"id"`
+	ProductID      uint       `gorm:"not null;uniqueIndex:idx_reviews_product_user" json:"product_id"`
+	Product        Product    `gorm:"foreignKey:ProductID;constraint:OnDelete:CASCADE" json:"-"`
+	UserID         uint       `gorm:"not null;uniqueIndex:idx_reviews_product_user" json:"user_id"`
+	User           User       `gorm:"foreignKey:UserID" json:"-"`
+	Rating         int        `gorm:"not null" json:"rating"` // 1 to 5 stars
+	Title          string     `gorm:"type:varchar(150);not null" json:"title"`
+	Body           string     `gorm:"type:text" json:"body"`
+	Status         string     `gorm:"type:varchar(20);default:'pending';index" json:"status"` // 'pending', 'approved' or 'rejected'
+	ModerationNote string     `json:"moderation_note,omitempty"`
+	ModeratedBy    *uint      `json:"moderated_by,omitempty"`
+	ModeratedAt    *time.Time `json:"moderated_at,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+}
@@ -0,0 +1,14 @@
+package models
+
+import (
+	"time"
+)
+
+// ProductSlugRedirect keeps a product's previous slug so old URLs can be redirected
+type ProductSlugRedirect struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	Slug      string    `gorm:"type:varchar(160);uniqueIndex;not null" json:"slug"`
+	ProductID uint      `gorm:"not null;index" json:"product_id"`
+	Product   Product   `gorm:"foreignKey:ProductID;constraint:OnDelete:CASCADE" json:"-"`
+	CreatedAt time.Time `json:"created_at"`
+}
@@ -0,0 +1,35 @@
+package models
+
+import (
+	"time"
+)
+
+// StockCount is a physical count of stock at one warehouse. Counts are recorded against it,
+// reviewed as a variance report, and then applied to the stock levels in one go.
+type StockCount struct {
+	ID          uint             `gorm:"primaryKey" json:"id"`
+	WarehouseID uint             `gorm:"not null;index" json:"warehouse_id"`
+	Warehouse   Warehouse        `gorm:"foreignKey:WarehouseID;constraint:OnDelete:RESTRICT" json:"warehouse"`
+	Status      string           `gorm:"type:varchar(20);not null;default:'open';index" json:"status"` // open, applied or cancelled; only open counts take new lines
+	Note        string           `json:"note,omitempty"`
+	CreatedBy   uint             `gorm:"not null" json:"created_by"`
+	AppliedBy   *uint            `json:"applied_by,omitempty"`
+	AppliedAt   *time.Time       `json:"applied_at,omitempty"`
+	CreatedAt   time.Time        `json:"created_at"`
+	UpdatedAt   time.Time        `json:"updated_at"`
+	Lines       []StockCountLine `gorm:"foreignKey:CountID;constraint:OnDelete:CASCADE" json:"lines,omitempty"`
+}
+
+// StockCountLine is the counted quantity of one product in a stock count.
+// Expected and Variance are fixed when the count is applied; until then they are worked out
+// from the current stock level.
+type StockCountLine struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	CountID   uint      `gorm:"not null;uniqueIndex:idx_stock_count_lines_count_product" json:"count_id"`
+	ProductID uint      `gorm:"not null;uniqueIndex:idx_stock_count_lines_count_product" json:"product_id"`
+	Product   Product   `gorm:"foreignKey:ProductID;constraint:OnDelete:CASCADE" json:"-"`
+	Counted   int       `gorm:"not null" json:"counted"`
+	Expected  *int      `json:"expected,omitempty"` // Stock level when the count was applied
+	Variance  *int      `json:"variance,omitempty"` // Counted less Expected
+	UpdatedAt time.Time `json:"updated_at"`
+}
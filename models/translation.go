@@ -0,0 +1,17 @@
+package models
+
+import (
+	"time"
+)
+
+// ProductTranslation holds a product's name and description in one locale
+type ProductTranslation struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	ProductID   uint      `gorm:"not null;uniqueIndex:idx_product_translations_product_locale" json:"product_id"`
+	Product     Product   `gorm:"foreignKey:ProductID;constraint:OnDelete:CASCADE" json:"-"`
+	Locale      string    `gorm:"type:varchar(35);not null;uniqueIndex:idx_product_translations_product_locale" json:"locale"` // BCP 47 tag, e.g. "pt-BR"
+	Name        string    `gorm:"not null" json:"name"`
+	Description string    `json:"description"` // Falls back along the locale chain when empty
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
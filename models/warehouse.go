@@ -0,0 +1,26 @@
+package models
+
+import (
+	"time"
+)
+
+// Warehouse is a location stock is held and shipped from
+type Warehouse struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	Code      string    `gorm:"type:varchar(32);uniqueIndex;not null" json:"code"`
+	Name      string    `gorm:"not null" json:"name"`
+	Priority  int       `gorm:"not null;default:100" json:"priority"` // Lower values are fulfilled from first
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// StockLevel is the stock of one product at one warehouse. Product.Stock is the sum over all warehouses.
+type StockLevel struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	WarehouseID uint      `gorm:"not null;uniqueIndex:idx_stock_levels_warehouse_product" json:"warehouse_id"`
+	Warehouse   Warehouse `gorm:"foreignKey:WarehouseID;constraint:OnDelete:RESTRICT" json:"warehouse"`
+	ProductID   uint      `gorm:"not null;uniqueIndex:idx_stock_levels_warehouse_product;index" json:"product_id"`
+	Product     Product   `gorm:"foreignKey:ProductID;constraint:OnDelete:CASCADE" json:"-"`
+	Quantity    int       `gorm:"not null;default:0" json:"quantity"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
@@ -14,21 +14,99 @@ func SetupRoutes() *gin.Engine {
 	router.POST("/register", controllers.RegisterUser)
 	router.POST("/login", controllers.LoginUser)
 
-	// Protected routes (Requires JWT)
+	// Signed download links carry their own authorization
+	router.GET("/downloads/:token", controllers.Download)
+
+	// Protected routes (Requires JWT and the admin role)
 	protected := router.Group("/api")
 	protected.Use(middleware.JWTMiddleware())
+	protected.Use(middleware.IdempotencyMiddleware())
 
-	// Product routes (Admin-only access)
+	// Product routes
 	protected.POST("/products", controllers.CreateProduct)
 	protected.PUT("/products/:id", controllers.UpdateProduct)
 	protected.DELETE("/products/:id", controllers.DeleteProduct)
-	protected.GET("/products", controllers.GetProducts)
+	protected.GET("/products/:id/prices", controllers.GetPriceHistory)
+	protected.POST("/products/:id/price-schedules", controllers.CreatePriceSchedule)
+	protected.GET("/products/:id/price-schedules", controllers.ListPriceSchedules)
+	protected.DELETE("/price-schedules/:id", controllers.CancelPriceSchedule)
+	protected.GET("/products/export", controllers.ExportProducts)
+	protected.POST("/products/import", controllers.ImportProducts)
+	protected.GET("/products/import/:id", controllers.GetImportJob)
+	protected.PUT("/products/:id/components", controllers.SetBundleComponents)
+	protected.GET("/products/:id/translations", controllers.ListProductTranslations)
+	protected.PUT("/products/:id/translations/:locale", controllers.SetProductTranslation)
+	protected.DELETE("/products/:id/translations/:locale", controllers.DeleteProductTranslation)
+
+	// Digital product routes
+	protected.POST("/products/:id/files", controllers.UploadProductFile)
+	protected.GET("/products/:id/files", controllers.ListProductFiles)
+	protected.DELETE("/products/:id/files/:file_id", controllers.DeleteProductFile)
+
+	// Review moderation routes
+	protected.GET("/reviews", controllers.ListReviews)
+	protected.PUT("/reviews/:id/approve", controllers.ApproveReview)
+	protected.PUT("/reviews/:id/reject", controllers.RejectReview)
+
+	// Recommendation routes
+	protected.GET("/products/:id/recommendation-overrides", controllers.ListRecommendationOverrides)
+	protected.POST("/products/:id/recommendation-overrides", controllers.SetRecommendationOverride)
+	protected.DELETE("/recommendation-overrides/:id", controllers.DeleteRecommendationOverride)
+	protected.POST("/recommendations/refresh", controllers.RefreshRecommendations)
+
+	// Warehouse and stock level routes
+	protected.POST("/warehouses", controllers.CreateWarehouse)
+	protected.GET("/warehouses", controllers.ListWarehouses)
+	protected.PUT("/warehouses/:id", controllers.UpdateWarehouse)
+	protected.GET("/products/:id/stock", controllers.GetProductStock)
+	protected.PUT("/products/:id/stock/:warehouse_id", controllers.SetProductStockLevel)
+	protected.GET("/inventory/movements", controllers.ListInventoryMovements)
+	protected.GET("/inventory/low-stock", controllers.GetLowStockReport)
+	protected.POST("/inventory/counts", controllers.CreateStockCount)
+	protected.GET("/inventory/counts", controllers.ListStockCounts)
+	protected.GET("/inventory/counts/:id", controllers.GetStockCount)
+	protected.DELETE("/inventory/counts/:id", controllers.CancelStockCount)
+	protected.POST("/inventory/counts/:id/lines", controllers.RecordStockCountLines)
+	protected.GET("/inventory/counts/:id/variance", controllers.GetStockCountVariance)
+	protected.POST("/inventory/counts/:id/apply", controllers.ApplyStockCount)
+
+	// Attribute definition routes
+	protected.POST("/attributes", controllers.CreateAttribute)
+	protected.PUT("/attributes/:id", controllers.UpdateAttribute)
+	protected.DELETE("/attributes/:id", controllers.DeleteAttribute)
+
+	// Order search (Admin-only access)
+	protected.GET("/orders/search", controllers.SearchOrders)
+
+	// Customer routes (Requires JWT, open to every role)
+	customer := router.Group("/api")
+	customer.Use(middleware.CustomerJWTMiddleware())
+	customer.Use(middleware.IdempotencyMiddleware())
+
+	// Catalog routes
+	customer.GET("/products", controllers.GetProducts)
+	customer.GET("/products/:id", controllers.GetProduct)
+	customer.GET("/products/slug/:slug", controllers.GetProductBySlug)
+	customer.GET("/products/:id/recommendations", controllers.GetRecommendations)
+	customer.GET("/attributes", controllers.ListAttributes)
+
+	// Review routes
+	customer.POST("/products/:id/reviews", controllers.CreateReview)
+	customer.GET("/products/:id/reviews", controllers.ListProductReviews)
+
+	// Wishlist and notification routes
+	customer.GET("/wishlist", controllers.GetWishlist)
+	customer.POST("/wishlist", controllers.AddToWishlist)
+	customer.DELETE("/wishlist/:product_id", controllers.RemoveFromWishlist)
+	customer.GET("/notifications", controllers.ListNotifications)
 
 	// Order routes
-	protected.POST("/orders", controllers.PlaceOrder)
-	protected.GET("/orders", controllers.ListUserOrders)
-	protected.PUT("/orders/:id/cancel", controllers.CancelOrder)
-	protected.PUT("/orders/:id", controllers.UpdateOrderStatus)
+	customer.POST("/orders", controllers.PlaceOrder)
+	customer.GET("/orders", controllers.ListUserOrders)
+	customer.GET("/orders/:id", controllers.GetOrder)
+	customer.GET("/orders/:id/downloads", controllers.ListOrderDownloads)
+	customer.PUT("/orders/:id/cancel", controllers.CancelOrder)
+	customer.PUT("/orders/:id", controllers.UpdateOrderStatus)
 
 	return router
 }
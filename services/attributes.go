@@ -0,0 +1,190 @@
+package services
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"go-ecommerce-api/models"
+
+	"gorm.io/gorm"
+)
+
+// Attribute types an AttributeDefinition may declare
+const (
+	AttributeString  = "string"
+	AttributeNumber  = "number"
+	AttributeEnum    = "enum"
+	AttributeBoolean = "boolean"
+)
+
+// AttributeError describes why a single attribute value was rejected
+type AttributeError struct {
+	Key     string `json:"key"`
+	Message string `json:"message"`
+}
+
+// attributeDefinitions loads every attribute definition keyed by its Key
+func attributeDefinitions(tx *gorm.DB) (map[string]models.AttributeDefinition, error) {
+	var definitions []models.AttributeDefinition
+	if err := tx.Find(&definitions).Error; err != nil {
+		return nil, err
+	}
+
+	byKey := make(map[string]models.AttributeDefinition, len(definitions))
+	for _, definition := range definitions {
+		byKey[definition.Key] = definition
+	}
+	return byKey, nil
+}
+
+// ValidateAttributes checks product attribute values against their definitions.
+// Unknown keys, values of the wrong type and missing required attributes are all reported.
+func ValidateAttributes(tx *gorm.DB, values map[string]interface{}) ([]AttributeError, error) {
+	definitions, err := attributeDefinitions(tx)
+	if err != nil {
+		return nil, err
+	}
+
+	var problems []AttributeError
+	for key, value := range values {
+		definition, ok := definitions[key]
+		if !ok {
+			problems = append(problems, AttributeError{Key: key, Message: "unknown attribute"})
+			continue
+		}
+		if message := checkAttributeValue(definition, value); message != "" {
+			problems = append(problems, AttributeError{Key: key, Message: message})
+		}
+	}
+
+	for key, definition := range definitions {
+		if _, ok := values[key]; definition.Required && !ok {
+			problems = append(problems, AttributeError{Key: key, Message: "attribute is required"})
+		}
+	}
+
+	return problems, nil
+}
+
+func checkAttributeValue(definition models.AttributeDefinition, value interface{}) string {
+	switch definition.Type {
+	case AttributeString:
+		if _, ok := value.(string); !ok {
+			return "must be a string"
+		}
+	case AttributeNumber:
+		if _, ok := value.(float64); !ok {
+			return "must be a number"
+		}
+	case AttributeBoolean:
+		if _, ok := value.(bool); !ok {
+			return "must be a boolean"
+		}
+	case AttributeEnum:
+		s, ok := value.(string)
+		if !ok || !containsString(definition.Options, s) {
+			return "must be one of " + strings.Join(definition.Options, ", ")
+		}
+	}
+
+	return ""
+}
+
+// AttributeChangeConflicts counts the products whose values of an attribute would stop validating
+// if its definition changed from current to updated: products without a value once it becomes
+// required, and products using an enum option that is removed
+func AttributeChangeConflicts(tx *gorm.DB, current, updated models.AttributeDefinition) (int64, error) {
+	var removed []string
+	if current.Type == AttributeEnum {
+		for _, option := range current.Options {
+			if !containsString(updated.Options, option) {
+				removed = append(removed, option)
+			}
+		}
+	}
+	becomesRequired := updated.Required && !current.Required
+	if !becomesRequired && len(removed) == 0 {
+		return 0, nil
+	}
+
+	query := tx.Model(&models.Product{})
+	switch {
+	case becomesRequired && len(removed) > 0:
+		query = query.Where("attributes IS NULL OR NOT jsonb_exists(attributes, ?) OR attributes ->> ? IN ?", current.Key, current.Key, removed)
+	case becomesRequired:
+		query = query.Where("attributes IS NULL OR NOT jsonb_exists(attributes, ?)", current.Key)
+	default:
+		query = query.Where("attributes ->> ? IN ?", current.Key, removed)
+	}
+
+	var count int64
+	err := query.Count(&count).Error
+	return count, err
+}
+
+// FilterByAttributes narrows a product query to products whose attributes match every filter.
+// Numbers may also be filtered by range through the min and max maps.
+func FilterByAttributes(tx *gorm.DB, query *gorm.DB, equals, min, max map[string]string) (*gorm.DB, error) {
+	definitions, err := attributeDefinitions(tx)
+	if err != nil {
+		return nil, err
+	}
+
+	lookup := func(key string) (models.AttributeDefinition, error) {
+		definition, ok := definitions[key]
+		if !ok {
+			return definition, fmt.Errorf("unknown attribute %q", key)
+		}
+		return definition, nil
+	}
+
+	for key, value := range equals {
+		definition, err := lookup(key)
+		if err != nil {
+			return nil, err
+		}
+
+		switch definition.Type {
+		case AttributeNumber:
+			number, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				return nil, fmt.Errorf("attribute %q must be filtered by a number", key)
+			}
+			query = query.Where("(attributes ->> ?)::numeric = ?", key, number)
+		case AttributeBoolean:
+			flag, err := strconv.ParseBool(value)
+			if err != nil {
+				return nil, fmt.Errorf("attribute %q must be filtered by true or false", key)
+			}
+			query = query.Where("(attributes ->> ?)::boolean = ?", key, flag)
+		default:
+			query = query.Where("attributes ->> ? = ?", key, value)
+		}
+	}
+
+	for operator, bounds := range map[string]map[string]string{">=": min, "<=": max} {
+		for key, value := range bounds {
+			definition, err := lookup(key)
+			if err != nil {
+				return nil, err
+			}
+			number, err := strconv.ParseFloat(value, 64)
+			if definition.Type != AttributeNumber || err != nil {
+				return nil, fmt.Errorf("range filters on %q need a number attribute and a numeric bound", key)
+			}
+			query = query.Where("jsonb_typeof(attributes -> ?) = 'number' AND (attributes ->> ?)::numeric "+operator+" ?", key, key, number)
+		}
+	}
+
+	return query, nil
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,93 @@
+package services
+
+import (
+	"time"
+
+	"go-ecommerce-api/models"
+
+	"gorm.io/gorm"
+)
+
+// Backorder policies, deciding whether a product can be ordered beyond its stock
+const (
+	// BackorderNone sells a product from its stock only
+	BackorderNone = "none"
+
+	// BackorderAllow keeps a product purchasable when it runs out; the missing units ship once
+	// it is restocked
+	BackorderAllow = "backorder"
+
+	// BackorderPreOrder makes a scheduled product purchasable before it launches; the order ships
+	// once it has. After launch the product sells from its stock only.
+	BackorderPreOrder = "preorder"
+)
+
+// SellableProducts is a query scope limiting products to those customers may see and buy:
+// published products, and scheduled ones that take pre-orders
+func SellableProducts(db *gorm.DB) *gorm.DB {
+	return db.Where("(products.status = ? OR (products.status = ? AND products.backorder_policy = ?))",
+		ProductPublished, ProductScheduled, BackorderPreOrder)
+}
+
+// IsPreOrder reports whether product is taking pre-orders, i.e. can be bought before it launches
+func IsPreOrder(product models.Product) bool {
+	return product.Status == ProductScheduled && product.BackorderPolicy == BackorderPreOrder
+}
+
+// oversellAllowance returns how many units of product may be ordered beyond its unreserved stock,
+// or -1 when there is no limit
+func oversellAllowance(product models.Product) int {
+	if product.BackorderPolicy != BackorderAllow && !IsPreOrder(product) {
+		return 0
+	}
+	if product.BackorderLimit == 0 {
+		return -1
+	}
+	return product.BackorderLimit
+}
+
+// expectedAt is when units of product that cannot ship now are expected to: the date set on the
+// product, or for pre-orders its launch
+func expectedAt(product models.Product) *time.Time {
+	if product.ExpectedAt == nil && IsPreOrder(product) {
+		return product.PublishAt
+	}
+	return product.ExpectedAt
+}
+
+// holdBack marks an order line whose units cannot all ship straight away
+func holdBack(item *models.OrderItem, product models.Product, backordered int, preOrder bool) {
+	item.Backordered = backordered
+	item.PreOrder = preOrder
+	if backordered > 0 || preOrder {
+		item.ExpectedAt = expectedAt(product)
+	}
+}
+
+// holdBackBundle marks a bundle line and its component lines. Component lines carry their own
+// backordered units; the bundle line counts the whole bundles that wait on them.
+func holdBackBundle(item *models.OrderItem, bundle models.Product, components []models.BundleComponent, backordered map[uint]int) {
+	preOrder := IsPreOrder(bundle)
+	held := 0
+	for j, component := range components {
+		short := backordered[component.ComponentID]
+		if preOrder {
+			holdBack(&item.Components[j], bundle, short, true)
+		} else {
+			holdBack(&item.Components[j], component.Component, short, false)
+		}
+		held = max(held, (short+component.Quantity-1)/component.Quantity)
+	}
+
+	holdBack(item, bundle, held, preOrder)
+	if preOrder {
+		return
+	}
+	// A backordered bundle ships when its last component arrives
+	item.ExpectedAt = nil
+	for _, component := range item.Components {
+		if component.ExpectedAt != nil && (item.ExpectedAt == nil || component.ExpectedAt.After(*item.ExpectedAt)) {
+			item.ExpectedAt = component.ExpectedAt
+		}
+	}
+}
@@ -0,0 +1,196 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"go-ecommerce-api/models"
+
+	"gorm.io/gorm"
+)
+
+// ErrInvalidBundle is returned when bundle components are rejected
+var ErrInvalidBundle = errors.New("invalid bundle")
+
+// SetBundleComponents replaces the components of bundle. An empty list turns the product back into
+// a regular product. Bundles cannot be nested, so components must not be bundles themselves.
+func SetBundleComponents(tx *gorm.DB, bundle *models.Product, components []models.BundleComponent) error {
+	if len(components) > 0 {
+		var usedIn int64
+		if err := tx.Model(&models.BundleComponent{}).Where("component_id = ?", bundle.ID).Count(&usedIn).Error; err != nil {
+			return err
+		}
+		if usedIn > 0 {
+			return fmt.Errorf("%w: product is a component of another bundle", ErrInvalidBundle)
+		}
+	}
+
+	seen := make(map[uint]bool, len(components))
+	for i := range components {
+		componentID := components[i].ComponentID
+		if componentID == bundle.ID {
+			return fmt.Errorf("%w: a bundle cannot contain itself", ErrInvalidBundle)
+		}
+		if seen[componentID] {
+			return fmt.Errorf("%w: product %d is listed more than once", ErrInvalidBundle, componentID)
+		}
+		seen[componentID] = true
+
+		var component models.Product
+		if err := tx.First(&component, componentID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return fmt.Errorf("%w: product %d does not exist", ErrInvalidBundle, componentID)
+			}
+			return err
+		}
+		if component.IsBundle {
+			return fmt.Errorf("%w: product %d is itself a bundle", ErrInvalidBundle, componentID)
+		}
+
+		components[i].ID = 0
+		components[i].BundleID = bundle.ID
+		components[i].Component = component
+	}
+
+	if err := tx.Where("bundle_id = ?", bundle.ID).Delete(&models.BundleComponent{}).Error; err != nil {
+		return err
+	}
+	if len(components) > 0 {
+		if err := tx.Omit("Component").Create(&components).Error; err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Model(bundle).Updates(map[string]interface{}{
+		"is_bundle": len(components) > 0,
+		"version":   gorm.Expr("version + 1"),
+	}).Error; err != nil {
+		return err
+	}
+
+	bundle.Version++
+	bundle.Components = components
+	return nil
+}
+
+// LoadAvailability fills in Available for each product: its stock less active reservations plus
+// what its backorder policy allows, or for a bundle the number of complete bundles its components
+// allow. Products that never run out, or can be backordered without limit, are left nil.
+func LoadAvailability(tx *gorm.DB, products []models.Product) error {
+	components := make([][]models.BundleComponent, len(products))
+	var ids []uint
+	for i, product := range products {
+		if product.TracksStock() {
+			ids = append(ids, product.ID)
+		}
+		if !product.IsBundle {
+			continue
+		}
+
+		var err error
+		if components[i], err = bundleComponents(tx, product.ID); err != nil {
+			return err
+		}
+		for _, component := range components[i] {
+			ids = append(ids, component.ComponentID)
+		}
+	}
+
+	reserved, err := reservedQuantities(tx, ids)
+	if err != nil {
+		return err
+	}
+
+	for i := range products {
+		product := &products[i]
+		if product.IsBundle {
+			product.Available = bundleAvailability(components[i], reserved)
+		} else if allowance := oversellAllowance(*product); product.TracksStock() && allowance >= 0 {
+			available := max(product.Stock-reserved[product.ID]+allowance, 0)
+			product.Available = &available
+		}
+	}
+
+	return nil
+}
+
+// bundleAvailability is the number of complete bundles the components' unreserved stock and
+// backorder allowances allow, or nil if no component runs out
+func bundleAvailability(components []models.BundleComponent, reserved map[uint]int) *int {
+	if len(components) == 0 {
+		zero := 0
+		return &zero
+	}
+
+	var available *int
+	for _, component := range components {
+		allowance := oversellAllowance(component.Component)
+		if !component.Component.TracksStock() || allowance < 0 {
+			continue
+		}
+		units := max(component.Component.Stock-reserved[component.ComponentID]+allowance, 0) / component.Quantity
+		if available == nil || units < *available {
+			available = &units
+		}
+	}
+	return available
+}
+
+// bundleComponents loads the components of a bundle in component ID order
+func bundleComponents(tx *gorm.DB, bundleID uint) ([]models.BundleComponent, error) {
+	var components []models.BundleComponent
+	err := tx.Preload("Component").Where("bundle_id = ?", bundleID).Order("component_id").Find(&components).Error
+	return components, err
+}
+
+// bundleItems returns one order line per component of quantity bundles, with the bundle price
+// allocated across them in proportion to the components' own prices
+func bundleItems(bundle models.Product, components []models.BundleComponent, quantity int) []models.OrderItem {
+	allocations := allocateBundlePrice(bundle.Price, components)
+	items := make([]models.OrderItem, len(components))
+	for i, component := range components {
+		items[i] = models.OrderItem{
+			ProductID:  &components[i].ComponentID,
+			Quantity:   component.Quantity * quantity,
+			Price:      math.Round(allocations[i]/float64(component.Quantity)*100) / 100,
+			Allocation: allocations[i],
+		}
+		snapshotProduct(&items[i], component.Component)
+	}
+	return items
+}
+
+// allocateBundlePrice splits the price of one bundle across its components, weighted by each
+// component's list price times its quantity. Shares are whole cents that add up to the bundle
+// price exactly; any rounding remainder goes to the first component.
+func allocateBundlePrice(price float64, components []models.BundleComponent) []float64 {
+	weights := make([]float64, len(components))
+	var total float64
+	for i, component := range components {
+		weights[i] = component.Component.Price * float64(component.Quantity)
+		total += weights[i]
+	}
+	// Free components would otherwise get nothing, so fall back to splitting by quantity
+	if total == 0 {
+		for i, component := range components {
+			weights[i] = float64(component.Quantity)
+			total += weights[i]
+		}
+	}
+
+	cents := int64(math.Round(price * 100))
+	shares := make([]int64, len(components))
+	var allocated int64
+	for i := range components {
+		shares[i] = int64(math.Floor(float64(cents) * weights[i] / total))
+		allocated += shares[i]
+	}
+	shares[0] += cents - allocated
+
+	allocations := make([]float64, len(components))
+	for i, share := range shares {
+		allocations[i] = float64(share) / 100
+	}
+	return allocations
+}
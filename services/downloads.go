@@ -0,0 +1,252 @@
+package services
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"go-ecommerce-api/models"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// grantLifetime is how long a buyer can keep downloading after the order is paid
+	grantLifetime = 30 * 24 * time.Hour
+
+	// linkLifetime is how long a single signed download link stays valid
+	linkLifetime = 15 * time.Minute
+)
+
+// Download errors returned by ConsumeDownload
+var (
+	ErrDownloadLinkInvalid = errors.New("download link is invalid or has expired")
+	ErrDownloadExhausted   = errors.New("download limit reached or entitlement expired")
+	ErrDownloadUnavailable = errors.New("file is missing from storage")
+)
+
+var (
+	signingKey     []byte
+	signingKeyOnce sync.Once
+)
+
+// downloadSigningKey is read lazily so that variables from .env are already loaded
+func downloadSigningKey() []byte {
+	signingKeyOnce.Do(func() {
+		if key := os.Getenv("DOWNLOAD_SIGNING_KEY"); key != "" {
+			signingKey = []byte(key)
+			return
+		}
+
+		// Without a configured key links still work, but only until the process restarts
+		logrus.Warn("DOWNLOAD_SIGNING_KEY is not set; download links will not survive a restart")
+		signingKey = make([]byte, 32)
+		if _, err := rand.Read(signingKey); err != nil {
+			panic(err)
+		}
+	})
+	return signingKey
+}
+
+// DownloadStorageDir is the local directory digital product files are stored in
+func DownloadStorageDir() string {
+	if dir := os.Getenv("DOWNLOAD_STORAGE_DIR"); dir != "" {
+		return dir
+	}
+	return filepath.Join("storage", "downloads")
+}
+
+// ProductFilePath returns a fresh storage path for a file uploaded to a product
+func ProductFilePath(productID uint, fileName string) (string, error) {
+	token, err := randomToken(8)
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(DownloadStorageDir(), strconv.FormatUint(uint64(productID), 10), token+"-"+filepath.Base(fileName)), nil
+}
+
+// IssueDownloadGrants entitles the buyer of an order to every file of the digital products in it.
+// Grants that already exist are left alone, so it is safe to call on every qualifying status change.
+func IssueDownloadGrants(tx *gorm.DB, order models.Order) error {
+	var items []models.OrderItem
+	if err := tx.Preload("Product").Where("order_id = ?", order.ID).Find(&items).Error; err != nil {
+		return err
+	}
+
+	expiresAt := time.Now().Add(grantLifetime)
+	for _, item := range items {
+		if !item.Product.IsDigital {
+			continue
+		}
+
+		var files []models.ProductFile
+		if err := tx.Where("product_id = ?", item.ProductID).Find(&files).Error; err != nil {
+			return err
+		}
+
+		for _, file := range files {
+			if err := issueDownloadGrant(tx, order.ID, order.UserID, file, item.Product.DownloadLimit*item.Quantity, expiresAt); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// GrantFileToBuyers entitles everyone who already paid for a digital product to a file attached
+// to it afterwards. The grant ends with the order's other downloads, or grantLifetime from now
+// if the order has none yet.
+func GrantFileToBuyers(tx *gorm.DB, file models.ProductFile) error {
+	var product models.Product
+	if err := tx.First(&product, file.ProductID).Error; err != nil {
+		return err
+	}
+
+	var buyers []struct {
+		OrderID   uint
+		UserID    uint
+		Quantity  int
+		ExpiresAt *time.Time
+	}
+	err := tx.Table("order_items").
+		Select("order_items.order_id, orders.user_id, order_items.quantity, (SELECT MAX(g.expires_at) FROM download_grants g WHERE g.order_id = order_items.order_id) AS expires_at").
+		Joins("JOIN orders ON orders.id = order_items.order_id").
+		Where("order_items.product_id = ? AND orders.status IN ?", file.ProductID, revenueStatuses).
+		Scan(&buyers).Error
+	if err != nil {
+		return err
+	}
+
+	for _, buyer := range buyers {
+		expiresAt := time.Now().Add(grantLifetime)
+		if buyer.ExpiresAt != nil {
+			expiresAt = *buyer.ExpiresAt
+		}
+		if err := issueDownloadGrant(tx, buyer.OrderID, buyer.UserID, file, product.DownloadLimit*buyer.Quantity, expiresAt); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// issueDownloadGrant entitles the buyer of an order to a file, unless the order already has a grant for it
+func issueDownloadGrant(tx *gorm.DB, orderID, userID uint, file models.ProductFile, maxDownloads int, expiresAt time.Time) error {
+	token, err := randomToken(32)
+	if err != nil {
+		return err
+	}
+
+	grant := models.DownloadGrant{
+		OrderID:       orderID,
+		ProductFileID: file.ID,
+		UserID:        userID,
+		Token:         token,
+		MaxDownloads:  maxDownloads,
+		ExpiresAt:     expiresAt,
+	}
+	return tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&grant).Error
+}
+
+// RevokeDownloadGrants withdraws every download grant of an order, e.g. when it is refunded
+func RevokeDownloadGrants(tx *gorm.DB, orderID uint) error {
+	return tx.Where("order_id = ?", orderID).Delete(&models.DownloadGrant{}).Error
+}
+
+// DownloadLink is a signed, short-lived URL for one download grant
+type DownloadLink struct {
+	Grant     models.DownloadGrant `json:"grant"`
+	URL       string               `json:"url"`
+	ExpiresAt time.Time            `json:"expires_at"`
+	Remaining int                  `json:"remaining"`
+}
+
+// SignDownloadLink builds a signed URL for a grant that is valid for linkLifetime,
+// or until the grant itself expires if that is sooner
+func SignDownloadLink(grant models.DownloadGrant) DownloadLink {
+	expiresAt := time.Now().Add(linkLifetime)
+	if grant.ExpiresAt.Before(expiresAt) {
+		expiresAt = grant.ExpiresAt
+	}
+
+	expires := strconv.FormatInt(expiresAt.Unix(), 10)
+	return DownloadLink{
+		Grant:     grant,
+		URL:       fmt.Sprintf("/downloads/%s?expires=%s&signature=%s", grant.Token, expires, downloadSignature(grant.Token, expires)),
+		ExpiresAt: expiresAt,
+		Remaining: grant.MaxDownloads - grant.DownloadCount,
+	}
+}
+
+// ConsumeDownload checks a signed download link and opens the file of its grant. The download
+// is only counted against the grant once the file has been opened, so a file missing from
+// storage does not use up the buyer's downloads, and a resumed download is not counted again.
+// The caller must close the returned file.
+func ConsumeDownload(tx *gorm.DB, token, expires, signature string, resume bool) (models.ProductFile, *os.File, error) {
+	expiresAt, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil || time.Now().Unix() > expiresAt || !hmac.Equal([]byte(signature), []byte(downloadSignature(token, expires))) {
+		return models.ProductFile{}, nil, ErrDownloadLinkInvalid
+	}
+
+	var file models.ProductFile
+	var content *os.File
+	err = tx.Transaction(func(tx *gorm.DB) error {
+		var grant models.DownloadGrant
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("token = ?", token).First(&grant).Error; err != nil {
+			return ErrDownloadLinkInvalid
+		}
+
+		// A range request before any download has been counted is counted like a new download
+		resume := resume && grant.DownloadCount > 0
+		if (!resume && grant.DownloadCount >= grant.MaxDownloads) || time.Now().After(grant.ExpiresAt) {
+			return ErrDownloadExhausted
+		}
+
+		if err := tx.First(&file, grant.ProductFileID).Error; err != nil {
+			return err
+		}
+
+		var err error
+		content, err = os.Open(file.StoragePath)
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrDownloadUnavailable, err)
+		}
+
+		if resume {
+			return nil
+		}
+		return tx.Model(&grant).UpdateColumn("download_count", gorm.Expr("download_count + 1")).Error
+	})
+	if err != nil && content != nil {
+		content.Close()
+		content = nil
+	}
+
+	return file, content, err
+}
+
+func downloadSignature(token, expires string) string {
+	mac := hmac.New(sha256.New, downloadSigningKey())
+	mac.Write([]byte(token + ":" + expires))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func randomToken(bytes int) (string, error) {
+	buf := make([]byte, bytes)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
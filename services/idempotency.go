@@ -0,0 +1,152 @@
+package services
+
+import (
+	"errors"
+	"net/http"
+	"os"
+	"time"
+
+	"go-ecommerce-api/database"
+	"go-ecommerce-api/models"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Idempotency key states
+const (
+	IdempotencyProcessing = "processing"
+	IdempotencyCompleted  = "completed"
+)
+
+// defaultIdempotencyKeyTTL is how long keys are remembered when IDEMPOTENCY_KEY_TTL is not set
+const defaultIdempotencyKeyTTL = 24 * time.Hour
+
+// idempotencyClaimTimeout is how long a key may stay processing before its request is taken to
+// have been lost, e.g. with a crashed process, and a retry may claim the key again
+const idempotencyClaimTimeout = 5 * time.Minute
+
+// Idempotency key errors
+var (
+	// ErrIdempotencyKeyReused is returned when a key is sent again with a different request
+	ErrIdempotencyKeyReused = errors.New("idempotency key was used for a different request")
+
+	// ErrIdempotencyKeyInFlight is returned when a key is sent again before its first request has finished
+	ErrIdempotencyKeyInFlight = errors.New("a request with this idempotency key is still being processed")
+
+	// ErrIdempotencyClaimLost is returned when a request finishes after a retry has taken over its key
+	ErrIdempotencyClaimLost = errors.New("idempotency key was claimed by a retry")
+)
+
+// IdempotencyKeyTTL is how long a key and its response are remembered for retries.
+// It is read from IDEMPOTENCY_KEY_TTL as a Go duration, e.g. "48h".
+func IdempotencyKeyTTL() time.Duration {
+	if ttl, err := time.ParseDuration(os.Getenv("IDEMPOTENCY_KEY_TTL")); err == nil && ttl > 0 {
+		return ttl
+	}
+	return defaultIdempotencyKeyTTL
+}
+
+// ClaimIdempotencyKey records that a user's request with key has started. claimed is true when
+// the caller should run the request and then complete or release the key. Otherwise the key is
+// returned with the response to replay, or an error if it belongs to a different request or its
+// first request is still running. Expired keys are claimed afresh, and so are keys whose request
+// has been processing for longer than idempotencyClaimTimeout when the same request is retried.
+// Taking a key over bumps its Attempt, so the abandoned request can no longer complete or release it.
+func ClaimIdempotencyKey(userID uint, key, fingerprint string) (models.IdempotencyKey, bool, error) {
+	now := time.Now()
+	record := models.IdempotencyKey{
+		UserID:      userID,
+		Key:         key,
+		Fingerprint: fingerprint,
+		Status:      IdempotencyProcessing,
+		Attempt:     1,
+		ExpiresAt:   now.Add(IdempotencyKeyTTL()),
+	}
+
+	result := database.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&record)
+	if result.Error != nil {
+		return record, false, result.Error
+	}
+	if result.RowsAffected == 1 {
+		return record, true, nil
+	}
+
+	// Take over a key that has expired but not been purged yet, or whose request was abandoned
+	result = database.DB.Model(&models.IdempotencyKey{}).
+		Where("user_id = ? AND key = ?", userID, key).
+		Where("expires_at <= ? OR (status = ? AND created_at <= ? AND fingerprint = ?)", now, IdempotencyProcessing, now.Add(-idempotencyClaimTimeout), fingerprint).
+		Updates(map[string]interface{}{
+			"fingerprint":   fingerprint,
+			"status":        IdempotencyProcessing,
+			"attempt":       gorm.Expr("attempt + 1"),
+			"response_code": 0,
+			"content_type":  "",
+			"etag":          "",
+			"location":      "",
+			"response_body": nil,
+			"expires_at":    record.ExpiresAt,
+			"created_at":    now,
+		})
+	if result.Error != nil {
+		return record, false, result.Error
+	}
+
+	var existing models.IdempotencyKey
+	if err := database.DB.Where("user_id = ? AND key = ?", userID, key).First(&existing).Error; err != nil {
+		return existing, false, err
+	}
+	if result.RowsAffected == 1 {
+		return existing, true, nil
+	}
+
+	switch {
+	case existing.Fingerprint != fingerprint:
+		return existing, false, ErrIdempotencyKeyReused
+	case existing.Status != IdempotencyCompleted:
+		return existing, false, ErrIdempotencyKeyInFlight
+	}
+	return existing, false, nil
+}
+
+// CompleteIdempotencyKey stores the response of a claimed key's request for replay, along with
+// the headers a retry needs to see. It fails with ErrIdempotencyClaimLost, leaving the key alone,
+// if a retry has taken the key over in the meantime.
+func CompleteIdempotencyKey(record models.IdempotencyKey, code int, header http.Header, body []byte) error {
+	result := database.DB.Model(&record).Where("attempt = ?", record.Attempt).Updates(map[string]interface{}{
+		"status":        IdempotencyCompleted,
+		"response_code": code,
+		"content_type":  header.Get("Content-Type"),
+		"etag":          header.Get("ETag"),
+		"location":      header.Get("Location"),
+		"response_body": body,
+	})
+	if result.Error == nil && result.RowsAffected == 0 {
+		return ErrIdempotencyClaimLost
+	}
+	return result.Error
+}
+
+// ReleaseIdempotencyKey forgets a claimed key whose request failed, so that a retry runs it again.
+// Like CompleteIdempotencyKey, it leaves a key a retry has taken over alone.
+func ReleaseIdempotencyKey(record models.IdempotencyKey) error {
+	result := database.DB.Where("attempt = ?", record.Attempt).Delete(&record)
+	if result.Error == nil && result.RowsAffected == 0 {
+		return ErrIdempotencyClaimLost
+	}
+	return result.Error
+}
+
+// PurgeExpiredIdempotencyKeys deletes keys that are past their expiry
+func PurgeExpiredIdempotencyKeys() error {
+	result := database.DB.Where("expires_at <= ?", time.Now()).Delete(&models.IdempotencyKey{})
+	if result.Error != nil {
+		return result.Error
+	}
+
+	if result.RowsAffected > 0 {
+		logrus.WithField("count", result.RowsAffected).Info("Purged expired idempotency keys")
+	}
+	return nil
+}
@@ -0,0 +1,261 @@
+package services
+
+import (
+	"fmt"
+	"time"
+
+	"go-ecommerce-api/database"
+	"go-ecommerce-api/models"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// Reasons recorded on inventory movements
+const (
+	MovementSale         = "sale"
+	MovementCancellation = "cancellation"
+	MovementAdjustment   = "adjustment"
+	MovementReturn       = "return"
+	MovementImport       = "import"
+)
+
+// StockChange describes why stock is moving, for the inventory ledger
+type StockChange struct {
+	Reason    string
+	ActorID   *uint
+	OrderID   *uint
+	Reference string
+	Note      string
+}
+
+// adjustStockLevel moves a product's stock at one warehouse by delta and appends the movement to
+// the inventory ledger. Every change to a stock level goes through here, which is what keeps the
+// ledger complete. It does not touch Product.Stock; see changeProductStock.
+func adjustStockLevel(tx *gorm.DB, productID, warehouseID uint, delta int, change StockChange) (models.StockLevel, error) {
+	level := models.StockLevel{WarehouseID: warehouseID, ProductID: productID}
+	if err := tx.Where(&level).FirstOrInit(&level).Error; err != nil {
+		return level, err
+	}
+	if delta == 0 {
+		return level, nil
+	}
+
+	level.Quantity += delta
+	if err := tx.Save(&level).Error; err != nil {
+		return level, err
+	}
+
+	return level, tx.Create(&models.InventoryMovement{
+		ProductID:   productID,
+		WarehouseID: warehouseID,
+		Reason:      change.Reason,
+		Delta:       delta,
+		ActorID:     change.ActorID,
+		OrderID:     change.OrderID,
+		Reference:   change.Reference,
+		Note:        change.Note,
+	}).Error
+}
+
+// changeProductStock moves a product's total stock by delta and runs the stock change side effects.
+// The caller must hold the product's row lock.
+func changeProductStock(tx *gorm.DB, product models.Product, delta int) error {
+	if delta == 0 {
+		return nil
+	}
+
+	previous := product.Stock
+	product.Stock += delta
+	if err := tx.Model(&product).Updates(map[string]interface{}{
+		"stock":   product.Stock,
+		"version": gorm.Expr("version + 1"),
+	}).Error; err != nil {
+		return err
+	}
+	return AfterStockChange(tx, product, previous)
+}
+
+// LedgerBalances derives a product's stock at each warehouse from the inventory ledger
+func LedgerBalances(tx *gorm.DB, productID uint) (map[uint]int, error) {
+	var rows []struct {
+		WarehouseID uint
+		Quantity    int
+	}
+	if err := tx.Model(&models.InventoryMovement{}).
+		Select("warehouse_id, SUM(delta) AS quantity").
+		Where("product_id = ?", productID).
+		Group("warehouse_id").Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	balances := make(map[uint]int, len(rows))
+	for _, row := range rows {
+		balances[row.WarehouseID] = row.Quantity
+	}
+	return balances, nil
+}
+
+// BackfillInventoryLedger records an opening balance for stock levels that predate the ledger,
+// so that every level can be derived from its movements
+func BackfillInventoryLedger() error {
+	result := database.DB.Exec(`
+		INSERT INTO inventory_movements (product_id, warehouse_id, reason, delta, reference, created_at)
+		SELECT l.product_id, l.warehouse_id, ?, l.quantity - COALESCE(SUM(m.delta), 0), 'opening balance', NOW()
+		FROM stock_levels l
+		LEFT JOIN inventory_movements m ON m.product_id = l.product_id AND m.warehouse_id = l.warehouse_id
+		GROUP BY l.product_id, l.warehouse_id, l.quantity
+		HAVING l.quantity <> COALESCE(SUM(m.delta), 0)`, MovementAdjustment)
+	if result.Error != nil {
+		return result.Error
+	}
+
+	if result.RowsAffected > 0 {
+		logrus.WithField("count", result.RowsAffected).Info("Recorded opening inventory balances")
+	}
+	return nil
+}
+
+// MovementFilter narrows an inventory ledger query; zero values match everything
+type MovementFilter struct {
+	ProductID   uint
+	WarehouseID uint
+	OrderID     uint
+	Reason      string
+}
+
+// FilterMovements applies filter to a query over the inventory ledger
+func FilterMovements(query *gorm.DB, filter MovementFilter) *gorm.DB {
+	if filter.ProductID != 0 {
+		query = query.Where("product_id = ?", filter.ProductID)
+	}
+	if filter.WarehouseID != 0 {
+		query = query.Where("warehouse_id = ?", filter.WarehouseID)
+	}
+	if filter.OrderID != 0 {
+		query = query.Where("order_id = ?", filter.OrderID)
+	}
+	if filter.Reason != "" {
+		query = query.Where("reason = ?", filter.Reason)
+	}
+	return query
+}
+
+// AfterStockChange runs the side effects of a product's stock moving from previous to product.Stock.
+// It must be called inside the transaction that changed the stock.
+func AfterStockChange(tx *gorm.DB, product models.Product, previous int) error {
+	if !product.TracksStock() {
+		return nil
+	}
+
+	// Customers are told a product is back once it can be bought again, i.e. once its stock
+	// rises past what active reservations already hold
+	reserved, err := reservedQuantities(tx, []uint{product.ID})
+	if err != nil {
+		return err
+	}
+	if previous-reserved[product.ID] <= 0 && product.Stock-reserved[product.ID] > 0 {
+		if err := notifyBackInStock(tx, product); err != nil {
+			return err
+		}
+	}
+
+	threshold := product.ReorderThreshold
+	if threshold > 0 && previous >= threshold && product.Stock < threshold {
+		return alertLowStock(tx, product)
+	}
+
+	return nil
+}
+
+// alertLowStock records a low-stock event for a product that just fell below its reorder
+// threshold and queues a notification for every admin
+func alertLowStock(tx *gorm.DB, product models.Product) error {
+	if err := tx.Create(&models.LowStockEvent{
+		ProductID: product.ID,
+		Stock:     product.Stock,
+		Threshold: product.ReorderThreshold,
+	}).Error; err != nil {
+		return err
+	}
+
+	var adminIDs []uint
+	if err := tx.Model(&models.User{}).Where("role = ?", "admin").Pluck("id", &adminIDs).Error; err != nil {
+		return err
+	}
+
+	subject := fmt.Sprintf("Low stock: %s", product.Name)
+	message := fmt.Sprintf("%s (SKU %s) is down to %d, below its reorder threshold of %d.", product.Name, product.SKU, product.Stock, product.ReorderThreshold)
+	for _, adminID := range adminIDs {
+		if err := QueueNotification(tx, adminID, "low_stock", subject, message); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// LowStockItem is a product whose stock is below its reorder threshold
+type LowStockItem struct {
+	Product     models.Product `json:"product"`
+	Shortfall   int            `json:"shortfall"`     // Units needed to get back to the threshold
+	LastAlertAt *time.Time     `json:"last_alert_at"` // When the latest low-stock event was recorded
+}
+
+// LowStockReport lists every stock-tracked product currently below its reorder threshold,
+// furthest below first
+func LowStockReport(tx *gorm.DB) ([]LowStockItem, error) {
+	var products []models.Product
+	if err := tx.Where("reorder_threshold > 0 AND stock < reorder_threshold AND NOT is_digital AND NOT is_bundle").
+		Order("stock - reorder_threshold, id").Find(&products).Error; err != nil {
+		return nil, err
+	}
+
+	ids := make([]uint, len(products))
+	for i, product := range products {
+		ids[i] = product.ID
+	}
+
+	var alerts []struct {
+		ProductID   uint
+		LastAlertAt time.Time
+	}
+	if len(ids) > 0 {
+		if err := tx.Model(&models.LowStockEvent{}).
+			Select("product_id, MAX(created_at) AS last_alert_at").
+			Where("product_id IN ?", ids).Group("product_id").Scan(&alerts).Error; err != nil {
+			return nil, err
+		}
+	}
+	lastAlert := make(map[uint]time.Time, len(alerts))
+	for _, alert := range alerts {
+		lastAlert[alert.ProductID] = alert.LastAlertAt
+	}
+
+	report := make([]LowStockItem, len(products))
+	for i, product := range products {
+		report[i] = LowStockItem{Product: product, Shortfall: product.ReorderThreshold - product.Stock}
+		if at, ok := lastAlert[product.ID]; ok {
+			report[i].LastAlertAt = &at
+		}
+	}
+	return report, nil
+}
+
+// notifyBackInStock queues a notification for everyone who wishlisted a product that was out of stock
+func notifyBackInStock(tx *gorm.DB, product models.Product) error {
+	var userIDs []uint
+	if err := tx.Model(&models.WishlistItem{}).Where("product_id = ?", product.ID).Pluck("user_id", &userIDs).Error; err != nil {
+		return err
+	}
+
+	for _, userID := range userIDs {
+		subject := fmt.Sprintf("%s is back in stock", product.Name)
+		message := fmt.Sprintf("Good news: %s from your wishlist is available again.", product.Name)
+		if err := QueueNotification(tx, userID, "back_in_stock", subject, message); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
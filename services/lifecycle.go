@@ -0,0 +1,111 @@
+package services
+
+import (
+	"time"
+
+	"go-ecommerce-api/database"
+	"go-ecommerce-api/models"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// Product publishing states
+const (
+	ProductDraft       = "draft"
+	ProductScheduled   = "scheduled"
+	ProductPublished   = "published"
+	ProductUnpublished = "unpublished"
+)
+
+// LifecycleError describes why a requested publishing state was rejected
+type LifecycleError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+func (e *LifecycleError) Error() string {
+	return e.Field + ": " + e.Message
+}
+
+// PublishedProducts is a query scope limiting products to those customers may see and buy
+func PublishedProducts(db *gorm.DB) *gorm.DB {
+	return db.Where("products.status = ?", ProductPublished)
+}
+
+// SetLifecycle validates a requested publishing state and applies it to product.
+// An empty status keeps the current one, and with it any publishing time not given. A scheduled
+// product whose publish time has already passed is published straight away, and going live
+// records the publish time.
+func SetLifecycle(product *models.Product, status string, publishAt, unpublishAt *time.Time, now time.Time) error {
+	if status == "" {
+		status = product.Status
+		if publishAt == nil {
+			publishAt = product.PublishAt
+		}
+		if unpublishAt == nil {
+			unpublishAt = product.UnpublishAt
+		}
+	}
+
+	if publishAt != nil && unpublishAt != nil && !unpublishAt.After(*publishAt) {
+		return &LifecycleError{Field: "unpublish_at", Message: "must be after publish_at"}
+	}
+
+	switch status {
+	case ProductScheduled:
+		if publishAt == nil {
+			return &LifecycleError{Field: "publish_at", Message: "is required for scheduled products"}
+		}
+		if !publishAt.After(now) {
+			status = ProductPublished
+		}
+	case ProductPublished:
+		if publishAt != nil && publishAt.After(now) {
+			return &LifecycleError{Field: "publish_at", Message: "is in the future; use status scheduled instead"}
+		}
+		if publishAt == nil {
+			// Republishing without a new time keeps the date the product first went live
+			if product.Status == ProductPublished && product.PublishAt != nil {
+				publishAt = product.PublishAt
+			} else {
+				publishAt = &now
+			}
+		}
+	}
+
+	// A kept unpublish time may be due already and is left for ApplyProductLifecycles to act on
+	if (status == ProductScheduled || status == ProductPublished) && unpublishAt != nil && unpublishAt != product.UnpublishAt && !unpublishAt.After(now) {
+		return &LifecycleError{Field: "unpublish_at", Message: "must be in the future"}
+	}
+
+	product.Status = status
+	product.PublishAt = publishAt
+	product.UnpublishAt = unpublishAt
+	return nil
+}
+
+// ApplyProductLifecycles publishes scheduled products whose publish time has come and
+// unpublishes published products whose unpublish time has passed
+func ApplyProductLifecycles() error {
+	now := time.Now()
+
+	published := database.DB.Model(&models.Product{}).
+		Where("status = ? AND publish_at <= ?", ProductScheduled, now).
+		Updates(map[string]interface{}{"status": ProductPublished, "version": gorm.Expr("version + 1")})
+	if published.Error != nil {
+		return published.Error
+	}
+
+	unpublished := database.DB.Model(&models.Product{}).
+		Where("status = ? AND unpublish_at <= ?", ProductPublished, now).
+		Updates(map[string]interface{}{"status": ProductUnpublished, "version": gorm.Expr("version + 1")})
+	if unpublished.Error != nil {
+		return unpublished.Error
+	}
+
+	if published.RowsAffected > 0 || unpublished.RowsAffected > 0 {
+		logrus.WithFields(logrus.Fields{"published": published.RowsAffected, "unpublished": unpublished.RowsAffected}).Info("Applied product publishing schedules")
+	}
+	return nil
+}
@@ -0,0 +1,87 @@
+package services
+
+import (
+	"time"
+
+	"go-ecommerce-api/database"
+	"go-ecommerce-api/models"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// maxDeliveryAttempts is how many times delivery is tried before a notification is marked failed
+const maxDeliveryAttempts = 5
+
+// Notifier delivers notifications over one channel, such as email or SMS
+type Notifier interface {
+	Name() string
+	Send(user models.User, notification models.Notification) error
+}
+
+// logNotifier writes notifications to the application log. It stands in until a
+// real email or SMS provider is configured.
+type logNotifier struct{}
+
+func (logNotifier) Name() string { return "log" }
+
+func (logNotifier) Send(user models.User, notification models.Notification) error {
+	logrus.WithFields(logrus.Fields{
+		"to":      user.Email,
+		"type":    notification.Type,
+		"subject": notification.Subject,
+	}).Info(notification.Message)
+	return nil
+}
+
+// notifiers are the channels every queued notification is delivered through
+var notifiers = []Notifier{logNotifier{}}
+
+// QueueNotification stores a notification for the delivery worker.
+// Pass the surrounding transaction so the notification is only sent if it commits.
+func QueueNotification(tx *gorm.DB, userID uint, kind, subject, message string) error {
+	return tx.Create(&models.Notification{
+		UserID:  userID,
+		Type:    kind,
+		Subject: subject,
+		Message: message,
+		Status:  "queued",
+	}).Error
+}
+
+// DeliverQueuedNotifications sends a batch of queued notifications through every channel.
+// Failed deliveries stay queued and are retried until maxDeliveryAttempts is reached.
+func DeliverQueuedNotifications() error {
+	var queued []models.Notification
+	if err := database.DB.Preload("User").Where("status = ?", "queued").Order("id").Limit(100).Find(&queued).Error; err != nil {
+		return err
+	}
+
+	for _, notification := range queued {
+		updates := map[string]interface{}{"attempts": notification.Attempts + 1}
+
+		var sendErr error
+		for _, notifier := range notifiers {
+			if err := notifier.Send(notification.User, notification); err != nil {
+				sendErr = err
+				logrus.WithError(err).WithFields(logrus.Fields{"notification_id": notification.ID, "channel": notifier.Name()}).Warn("Notification delivery failed")
+			}
+		}
+
+		if sendErr == nil {
+			updates["status"] = "sent"
+			updates["sent_at"] = time.Now()
+		} else {
+			updates["last_error"] = sendErr.Error()
+			if notification.Attempts+1 >= maxDeliveryAttempts {
+				updates["status"] = "failed"
+			}
+		}
+
+		if err := database.DB.Model(&notification).Updates(updates).Error; err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
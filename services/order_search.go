@@ -0,0 +1,81 @@
+package services
+
+import (
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// OrderFilter narrows an order query; zero values match everything
+type OrderFilter struct {
+	Statuses  []string
+	From      *time.Time // Placed at or after
+	To        *time.Time // Placed before
+	UserID    uint
+	Email     string // Part of the customer's email, matched case-insensitively
+	MinTotal  *float64
+	MaxTotal  *float64
+	ProductID uint // Contains the product, on its own or as part of a bundle
+}
+
+// OrderSorts maps the sort keys accepted by order searches to their columns
+var OrderSorts = map[string]string{
+	"id":           "orders.id",
+	"created_at":   "orders.created_at",
+	"total_amount": "orders.total_amount",
+	"status":       "orders.status",
+}
+
+// revenueStatuses are the statuses of orders that have been paid for and not given back
+var revenueStatuses = []string{OrderPaid, OrderProcessing, OrderShipped, OrderDelivered, OrderCompleted}
+
+// FilterOrders applies filter to a query over orders
+func FilterOrders(query *gorm.DB, filter OrderFilter) *gorm.DB {
+	if len(filter.Statuses) > 0 {
+		query = query.Where("orders.status IN ?", filter.Statuses)
+	}
+	if filter.From != nil {
+		query = query.Where("orders.created_at >= ?", *filter.From)
+	}
+	if filter.To != nil {
+		query = query.Where("orders.created_at < ?", *filter.To)
+	}
+	if filter.UserID != 0 {
+		query = query.Where("orders.user_id = ?", filter.UserID)
+	}
+	if filter.Email != "" {
+		query = query.Where("orders.user_id IN (SELECT id FROM users WHERE email ILIKE ?)", "%"+escapeLike(filter.Email)+"%")
+	}
+	if filter.MinTotal != nil {
+		query = query.Where("orders.total_amount >= ?", *filter.MinTotal)
+	}
+	if filter.MaxTotal != nil {
+		query = query.Where("orders.total_amount <= ?", *filter.MaxTotal)
+	}
+	if filter.ProductID != 0 {
+		query = query.Where("EXISTS (SELECT 1 FROM order_items i WHERE i.order_id = orders.id AND i.product_id = ?)", filter.ProductID)
+	}
+	return query
+}
+
+// OrderSummary aggregates the orders matching a search
+type OrderSummary struct {
+	Orders  int64   `json:"orders"`
+	Revenue float64 `json:"revenue"` // Total of the matching orders that are paid and neither cancelled nor refunded
+}
+
+// SummarizeOrders counts the orders of a filtered query and sums their revenue
+func SummarizeOrders(query *gorm.DB) (OrderSummary, error) {
+	var summary OrderSummary
+	err := query.Select("COUNT(*) AS orders, COALESCE(SUM(CASE WHEN orders.status IN ? THEN orders.total_amount ELSE 0 END), 0) AS revenue", revenueStatuses).
+		Scan(&summary).Error
+	return summary, err
+}
+
+// likeEscaper escapes the wildcards of a LIKE pattern so user input matches literally
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+func escapeLike(s string) string {
+	return likeEscaper.Replace(s)
+}
@@ -0,0 +1,165 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+
+	"go-ecommerce-api/database"
+	"go-ecommerce-api/models"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// Order statuses
+const (
+	OrderPending    = "pending"
+	OrderPaid       = "paid"
+	OrderProcessing = "processing"
+	OrderShipped    = "shipped"
+	OrderDelivered  = "delivered"
+	OrderCompleted  = "completed"
+	OrderCancelled  = "cancelled"
+	OrderRefunded   = "refunded"
+)
+
+// OrderStatuses lists every order status, in the order an order normally moves through them
+var OrderStatuses = []string{OrderPending, OrderPaid, OrderProcessing, OrderShipped, OrderDelivered, OrderCompleted, OrderCancelled, OrderRefunded}
+
+// Roles that move orders between statuses
+const (
+	OrderActorAdmin    = "admin"
+	OrderActorCustomer = "customer" // The user who placed the order
+	OrderActorSystem   = "system"   // Background jobs, such as expiring unpaid orders
+)
+
+// orderTransitions is the order state machine: for each status, the statuses an order may move
+// to next and the roles allowed to move it there. Anything not listed is rejected.
+var orderTransitions = map[string]map[string][]string{
+	OrderPending: {
+		OrderPaid:      {OrderActorAdmin},
+		OrderCancelled: {OrderActorCustomer, OrderActorAdmin, OrderActorSystem},
+	},
+	OrderPaid: {
+		OrderProcessing: {OrderActorAdmin},
+		OrderCancelled:  {OrderActorAdmin},
+		OrderRefunded:   {OrderActorAdmin},
+	},
+	OrderProcessing: {
+		OrderShipped:   {OrderActorAdmin},
+		OrderCancelled: {OrderActorAdmin},
+	},
+	OrderShipped: {
+		OrderDelivered: {OrderActorAdmin},
+	},
+	OrderDelivered: {
+		OrderCompleted: {OrderActorAdmin, OrderActorCustomer},
+		OrderRefunded:  {OrderActorAdmin},
+	},
+	OrderCompleted: {
+		OrderRefunded: {OrderActorAdmin},
+	},
+}
+
+// Order status errors
+var (
+	// ErrInvalidTransition is returned when an order cannot move from its status to the requested one
+	ErrInvalidTransition = errors.New("invalid order status transition")
+
+	// ErrTransitionForbidden is returned when the transition exists but the actor's role may not make it
+	ErrTransitionForbidden = errors.New("order status transition not permitted")
+)
+
+// CheckOrderTransition reports whether role may move an order from one status to another
+func CheckOrderTransition(from, to, role string) error {
+	roles, ok := orderTransitions[from][to]
+	if !ok {
+		return fmt.Errorf("%w: %s orders cannot become %s", ErrInvalidTransition, from, to)
+	}
+	if !slices.Contains(roles, role) {
+		return fmt.Errorf("%w: %s cannot move an order from %s to %s", ErrTransitionForbidden, role, from, to)
+	}
+	return nil
+}
+
+// OrderStatusChange describes who moved an order from which status, and why, for its history
+type OrderStatusChange struct {
+	From    string
+	Role    string
+	ActorID *uint
+	Note    string
+}
+
+// SettleOrderStatus records an order having moved to its current status in the order's history
+// and runs the side effects of the move:
+//   - paying turns the stock reservations into sales and unlocks downloads of digital products
+//   - cancelling gives reserved stock back, and restocks what was already sold
+//   - refunding restocks the order, recorded as a return in the inventory ledger once it was delivered
+//   - refunding or cancelling a paid order revokes its downloads
+func SettleOrderStatus(tx *gorm.DB, order models.Order, change OrderStatusChange) error {
+	if _, err := recordOrderEvent(tx, order, change); err != nil {
+		return err
+	}
+
+	from := change.From
+	restock := StockChange{Reason: MovementCancellation, ActorID: change.ActorID, OrderID: &order.ID}
+
+	switch order.Status {
+	case OrderPending:
+		return nil
+	case OrderPaid:
+		if err := CommitReservations(tx, order.ID); err != nil {
+			return err
+		}
+		return IssueDownloadGrants(tx, order)
+	case OrderCancelled:
+		if err := ReleaseReservations(tx, order.ID); err != nil {
+			return err
+		}
+		if from == OrderPending {
+			return nil
+		}
+		if err := RestockReservations(tx, order.ID, restock); err != nil {
+			return err
+		}
+		return RevokeDownloadGrants(tx, order.ID)
+	case OrderRefunded:
+		if from == OrderDelivered || from == OrderCompleted {
+			restock.Reason = MovementReturn
+		}
+		if err := RestockReservations(tx, order.ID, restock); err != nil {
+			return err
+		}
+		return RevokeDownloadGrants(tx, order.ID)
+	}
+
+	// Later statuses only follow payment, so this is a no-op unless stock was somehow left reserved
+	return CommitReservations(tx, order.ID)
+}
+
+// recordOrderEvent appends the move of an order to its current status to the order's history
+func recordOrderEvent(tx *gorm.DB, order models.Order, change OrderStatusChange) (models.OrderEvent, error) {
+	event := models.OrderEvent{
+		OrderID:    order.ID,
+		FromStatus: change.From,
+		ToStatus:   order.Status,
+		ActorID:    change.ActorID,
+		ActorRole:  change.Role,
+		Note:       change.Note,
+	}
+	return event, tx.Create(&event).Error
+}
+
+// NormalizeOrderStatuses lowercases statuses written before the state machine, e.g. "Pending"
+func NormalizeOrderStatuses() error {
+	result := database.DB.Model(&models.Order{}).Where("status <> LOWER(status)").Update("status", gorm.Expr("LOWER(status)"))
+	if result.Error != nil {
+		return result.Error
+	}
+
+	if result.RowsAffected > 0 {
+		logrus.WithField("count", result.RowsAffected).Info("Normalized order statuses")
+	}
+	return nil
+}
@@ -0,0 +1,305 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"go-ecommerce-api/database"
+	"go-ecommerce-api/models"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Order placement errors
+var (
+	ErrProductNotFound   = errors.New("product not found")
+	ErrInsufficientStock = errors.New("insufficient stock")
+)
+
+// OrderLine is one line of an order being placed
+type OrderLine struct {
+	ProductID uint `json:"product_id"`
+	Quantity  int  `json:"quantity"`
+}
+
+// LineError explains why one order line cannot be fulfilled
+type LineError struct {
+	Line      int    `json:"line"` // Index of the line in the request
+	ProductID uint   `json:"product_id"`
+	Requested int    `json:"requested"`
+	Available int    `json:"available"`
+	Message   string `json:"message"`
+}
+
+// StockError lists every order line that the current stock cannot cover
+type StockError struct {
+	Lines []LineError
+}
+
+func (e *StockError) Error() string {
+	messages := make([]string, len(e.Lines))
+	for i, line := range e.Lines {
+		messages[i] = line.Message
+	}
+	return strings.Join(messages, "; ")
+}
+
+func (e *StockError) Unwrap() error {
+	return ErrInsufficientStock
+}
+
+// PlaceOrder creates an order for userID in a single transaction and reserves the stock it needs
+// until the order is paid. The rows of every product whose stock is consumed are locked, in ID
+// order so concurrent checkouts cannot deadlock, before availability is checked and reserved, so
+// stock can never be oversold. Lines that cannot be covered are all reported together in a *StockError.
+func PlaceOrder(userID uint, lines []OrderLine) (models.Order, error) {
+	order := models.Order{
+		UserID:    userID,
+		Status:    OrderPending,
+		CreatedAt: time.Now(),
+	}
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		products := make([]models.Product, len(lines))
+		components := make([][]models.BundleComponent, len(lines))
+		for i, line := range lines {
+			if err := tx.Scopes(SellableProducts).First(&products[i], line.ProductID).Error; err != nil {
+				if errors.Is(err, gorm.ErrRecordNotFound) {
+					return fmt.Errorf("%w: %d", ErrProductNotFound, line.ProductID)
+				}
+				return err
+			}
+			if products[i].IsBundle {
+				var err error
+				if components[i], err = bundleComponents(tx, products[i].ID); err != nil {
+					return err
+				}
+			}
+		}
+
+		stock, err := lockStock(tx, products, components)
+		if err != nil {
+			return err
+		}
+
+		quantities, backordered, err := checkStock(tx, lines, products, components, stock)
+		if err != nil {
+			return err
+		}
+
+		for i, line := range lines {
+			order.TotalAmount += float64(line.Quantity) * products[i].Price
+		}
+		if err := tx.Create(&order).Error; err != nil {
+			return err
+		}
+		event, err := recordOrderEvent(tx, order, OrderStatusChange{Role: OrderActorCustomer, ActorID: &userID})
+		if err != nil {
+			return err
+		}
+		order.Events = []models.OrderEvent{event}
+		allocations, err := allocateStock(tx, quantities, oversold(backordered))
+		if err != nil {
+			return err
+		}
+		if err := reserveStock(tx, order.ID, allocations); err != nil {
+			return err
+		}
+
+		order.OrderItems = make([]models.OrderItem, len(lines))
+		for i, line := range lines {
+			item := &order.OrderItems[i]
+			*item = models.OrderItem{OrderID: order.ID, ProductID: &products[i].ID, Quantity: line.Quantity, Price: products[i].Price}
+			snapshotProduct(item, products[i])
+			if products[i].IsBundle {
+				// Bundles are fulfilled from their components, which are recorded under the bundle line
+				item.Components = bundleItems(products[i], components[i], line.Quantity)
+				holdBackBundle(item, products[i], components[i], backordered[i])
+			} else {
+				holdBack(item, products[i], backordered[i][products[i].ID], IsPreOrder(products[i]))
+			}
+			if err := tx.Omit("Components").Create(item).Error; err != nil {
+				return err
+			}
+			if len(item.Components) == 0 {
+				continue
+			}
+
+			for j := range item.Components {
+				item.Components[j].OrderID = order.ID
+				item.Components[j].BundleItemID = &item.ID
+			}
+			if err := tx.Create(&item.Components).Error; err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+
+	return order, err
+}
+
+// snapshotProduct copies what identifies a product onto an order line, so the line still makes
+// sense after the product is renamed or deleted
+func snapshotProduct(item *models.OrderItem, product models.Product) {
+	item.ProductName = product.Name
+	item.ProductSKU = product.SKU
+	item.ProductAttributes = product.Attributes
+}
+
+// BackfillOrderItemSnapshots copies product details onto order lines recorded before lines
+// carried them
+func BackfillOrderItemSnapshots() error {
+	result := database.DB.Exec(`
+		UPDATE order_items i
+		SET product_name = p.name, product_sku = p.sku, product_attributes = p.attributes
+		FROM products p
+		WHERE p.id = i.product_id AND i.product_name = ''`)
+	if result.Error != nil {
+		return result.Error
+	}
+
+	if result.RowsAffected > 0 {
+		logrus.WithField("count", result.RowsAffected).Info("Backfilled order item product snapshots")
+	}
+	return nil
+}
+
+// oversold totals the units of each product that an order takes beyond stock
+func oversold(backordered []map[uint]int) map[uint]int {
+	totals := map[uint]int{}
+	for _, line := range backordered {
+		for id, quantity := range line {
+			totals[id] += quantity
+		}
+	}
+	return totals
+}
+
+// lockStock locks every product whose stock the order consumes and returns them by ID.
+// Holding these locks serializes the reservations made against each product.
+func lockStock(tx *gorm.DB, products []models.Product, components [][]models.BundleComponent) (map[uint]*models.Product, error) {
+	var ids []uint
+	for i, product := range products {
+		if product.TracksStock() {
+			ids = append(ids, product.ID)
+		}
+		for _, component := range components[i] {
+			if component.Component.TracksStock() {
+				ids = append(ids, component.ComponentID)
+			}
+		}
+	}
+
+	stock := make(map[uint]*models.Product, len(ids))
+	if len(ids) == 0 {
+		return stock, nil
+	}
+
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	var locked []models.Product
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id IN ?", ids).Order("id").Find(&locked).Error; err != nil {
+		return nil, err
+	}
+
+	for i := range locked {
+		stock[locked[i].ID] = &locked[i]
+	}
+	return stock, nil
+}
+
+// checkStock checks every line against the stock that is neither sold nor reserved and returns
+// the quantity of each product the order needs, and for each line the units of each product that
+// are ordered beyond stock under its backorder policy. Lines are served in request order, so when
+// several lines compete for one product the later ones are reported or backordered.
+func checkStock(tx *gorm.DB, lines []OrderLine, products []models.Product, components [][]models.BundleComponent, stock map[uint]*models.Product) (map[uint]int, []map[uint]int, error) {
+	ids := make([]uint, 0, len(stock))
+	for id := range stock {
+		ids = append(ids, id)
+	}
+	reserved, err := reservedQuantities(tx, ids)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	remaining := make(map[uint]int, len(stock))
+	for id, product := range stock {
+		remaining[id] = product.Stock - reserved[id]
+	}
+
+	quantities := map[uint]int{}
+	backordered := make([]map[uint]int, len(lines))
+	var problems []LineError
+	for i, line := range lines {
+		needs := map[uint]int{}
+		if products[i].TracksStock() {
+			needs[products[i].ID] = line.Quantity
+		}
+		for _, component := range components[i] {
+			if component.Component.TracksStock() {
+				needs[component.ComponentID] += component.Quantity * line.Quantity
+			}
+		}
+		if products[i].IsBundle && len(components[i]) == 0 {
+			problems = append(problems, LineError{Line: i, ProductID: line.ProductID, Requested: line.Quantity,
+				Message: fmt.Sprintf("line %d: bundle %q has no components", i+1, products[i].Name)})
+			continue
+		}
+
+		if problem := shortfall(i, line, products[i], needs, remaining, stock); problem != nil {
+			problems = append(problems, *problem)
+			continue
+		}
+		backordered[i] = map[uint]int{}
+		for id, quantity := range needs {
+			if short := quantity - max(remaining[id], 0); short > 0 {
+				backordered[i][id] = short
+			}
+			remaining[id] -= quantity
+			quantities[id] += quantity
+		}
+	}
+
+	if len(problems) > 0 {
+		return nil, nil, &StockError{Lines: problems}
+	}
+	return quantities, backordered, nil
+}
+
+// shortfall reports the first product a line needs more of than remains, if any. Products that
+// can be backordered or pre-ordered may go below zero by their allowance.
+func shortfall(index int, line OrderLine, product models.Product, needs map[uint]int, remaining map[uint]int, stock map[uint]*models.Product) *LineError {
+	ids := make([]uint, 0, len(needs))
+	for id := range needs {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	for _, id := range ids {
+		allowance := oversellAllowance(*stock[id])
+		if allowance < 0 || needs[id] <= remaining[id]+allowance {
+			continue
+		}
+
+		// Stock lowered below what is already reserved leaves nothing, not a negative amount
+		available := max(remaining[id]+allowance, 0)
+		problem := &LineError{Line: index, ProductID: line.ProductID, Requested: line.Quantity, Available: available}
+		if id == product.ID {
+			problem.Message = fmt.Sprintf("line %d: only %d of %q available, %d requested", index+1, available, product.Name, line.Quantity)
+		} else {
+			// For bundles, report how many whole bundles the short component still allows
+			perBundle := needs[id] / line.Quantity
+			problem.Available = available / perBundle
+			problem.Message = fmt.Sprintf("line %d: only %d of %q available, which allows %d of %q, %d requested",
+				index+1, available, stock[id].Name, problem.Available, product.Name, line.Quantity)
+		}
+		return problem
+	}
+	return nil
+}
@@ -0,0 +1,158 @@
+package services
+
+import (
+	"errors"
+	"time"
+
+	"go-ecommerce-api/database"
+	"go-ecommerce-api/models"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Price change sources recorded in the price history
+const (
+	PriceSourceManual   = "manual"
+	PriceSourceImport   = "import"
+	PriceSourceSchedule = "schedule"
+)
+
+// ErrScheduleNotCancellable is returned when cancelling a price schedule that has already finished
+var ErrScheduleNotCancellable = errors.New("only scheduled or active price schedules can be cancelled")
+
+// RecordPriceChange appends a price history entry if the price actually changed
+func RecordPriceChange(tx *gorm.DB, productID uint, oldPrice, newPrice float64, source string, changedBy *uint, scheduleID *uint) error {
+	if oldPrice == newPrice {
+		return nil
+	}
+
+	return tx.Create(&models.PriceChange{
+		ProductID:  productID,
+		OldPrice:   oldPrice,
+		NewPrice:   newPrice,
+		Source:     source,
+		ChangedBy:  changedBy,
+		ScheduleID: scheduleID,
+	}).Error
+}
+
+// lockProductPrice loads a product's current price, locking the row until the transaction ends
+func lockProductPrice(tx *gorm.DB, productID uint) (models.Product, error) {
+	var product models.Product
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id", "price").First(&product, productID).Error
+	return product, err
+}
+
+// setScheduledPrice writes a price set by a schedule and records it in the history
+func setScheduledPrice(tx *gorm.DB, schedule *models.PriceSchedule, product models.Product, price float64) error {
+	oldPrice := product.Price
+	if err := tx.Model(&product).Updates(map[string]interface{}{
+		"price":   price,
+		"version": gorm.Expr("version + 1"),
+	}).Error; err != nil {
+		return err
+	}
+
+	return RecordPriceChange(tx, product.ID, oldPrice, price, PriceSourceSchedule, nil, &schedule.ID)
+}
+
+// startPriceSchedule applies a schedule's price, remembering the price it replaces
+func startPriceSchedule(tx *gorm.DB, schedule *models.PriceSchedule) error {
+	product, err := lockProductPrice(tx, schedule.ProductID)
+	if err != nil {
+		return err
+	}
+
+	if err := setScheduledPrice(tx, schedule, product, schedule.Price); err != nil {
+		return err
+	}
+
+	schedule.PreviousPrice = &product.Price
+	schedule.Status = "active"
+	if schedule.EndsAt == nil {
+		schedule.Status = "completed"
+	}
+
+	return tx.Save(schedule).Error
+}
+
+// endPriceSchedule restores the price an active schedule replaced. If the price was changed
+// by someone else while the schedule ran, that change wins and nothing is restored.
+func endPriceSchedule(tx *gorm.DB, schedule *models.PriceSchedule, status string) error {
+	product, err := lockProductPrice(tx, schedule.ProductID)
+	if err != nil {
+		return err
+	}
+
+	if schedule.PreviousPrice != nil && product.Price == schedule.Price {
+		if err := setScheduledPrice(tx, schedule, product, *schedule.PreviousPrice); err != nil {
+			return err
+		}
+	}
+
+	schedule.Status = status
+	return tx.Save(schedule).Error
+}
+
+// CancelPriceSchedule stops a pending schedule, or ends an active one early
+func CancelPriceSchedule(schedule *models.PriceSchedule) error {
+	return database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(schedule, schedule.ID).Error; err != nil {
+			return err
+		}
+
+		switch schedule.Status {
+		case "scheduled":
+			schedule.Status = "cancelled"
+			return tx.Save(schedule).Error
+		case "active":
+			return endPriceSchedule(tx, schedule, "cancelled")
+		default:
+			return ErrScheduleNotCancellable
+		}
+	})
+}
+
+// ApplyDuePriceSchedules starts schedules whose start time has passed and ends active
+// schedules whose end time has passed. Each schedule is applied in its own transaction.
+func ApplyDuePriceSchedules() error {
+	now := time.Now()
+
+	var due []models.PriceSchedule
+	if err := database.DB.Where("status = ? AND starts_at <= ?", "scheduled", now).Order("starts_at").Find(&due).Error; err != nil {
+		return err
+	}
+	for i := range due {
+		applyPriceSchedule(&due[i], "scheduled", startPriceSchedule)
+	}
+
+	var ended []models.PriceSchedule
+	if err := database.DB.Where("status = ? AND ends_at <= ?", "active", now).Order("ends_at").Find(&ended).Error; err != nil {
+		return err
+	}
+	for i := range ended {
+		applyPriceSchedule(&ended[i], "active", func(tx *gorm.DB, schedule *models.PriceSchedule) error {
+			return endPriceSchedule(tx, schedule, "completed")
+		})
+	}
+
+	return nil
+}
+
+// applyPriceSchedule runs fn on a schedule, skipping it if another worker already moved it on
+func applyPriceSchedule(schedule *models.PriceSchedule, expectedStatus string, fn func(*gorm.DB, *models.PriceSchedule) error) {
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(schedule, schedule.ID).Error; err != nil {
+			return err
+		}
+		if schedule.Status != expectedStatus {
+			return nil
+		}
+		return fn(tx, schedule)
+	})
+	if err != nil {
+		logrus.WithError(err).WithField("schedule_id", schedule.ID).Error("Failed to apply price schedule")
+	}
+}
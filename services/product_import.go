@@ -0,0 +1,431 @@
+package services
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"go-ecommerce-api/database"
+	"go-ecommerce-api/models"
+	"go-ecommerce-api/utils"
+
+	"github.com/gin-gonic/gin/binding"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Supported bulk import/export formats
+const (
+	FormatCSV    = "csv"
+	FormatNDJSON = "ndjson"
+)
+
+// ProductCSVHeader is the column order used for CSV exports; imports match columns by name
+var ProductCSVHeader = []string{"sku", "name", "description", "price", "stock"}
+
+// progressEvery controls how often (in rows) job progress is written back to the database
+const progressEvery = 100
+
+// ProductImportRow is a single product record read from an import file
+type ProductImportRow struct {
+	SKU   string  `json:"sku"`
+	Name  string  `json:"name"`
+	Price float64 `json:"price"`
+
+	// Description and Stock are optional; when nil an update leaves them untouched and a new
+	// product starts without a description and with no stock
+	Description *string `json:"description,omitempty"`
+	Stock       *int    `json:"stock,omitempty"`
+
+	// Attributes can only be supplied in NDJSON; when nil an update leaves them untouched
+	Attributes map[string]interface{} `json:"attributes,omitempty"`
+}
+
+// Validate applies the same rules as the product endpoints, plus a SKU to upsert by
+func (row ProductImportRow) Validate() error {
+	if row.SKU == "" {
+		return errors.New("sku: is required")
+	}
+
+	stock := 0
+	if row.Stock != nil {
+		stock = *row.Stock
+	}
+	input := models.ProductInput{
+		SKU:   row.SKU,
+		Name:  row.Name,
+		Price: &row.Price,
+		Stock: &stock,
+	}
+	if row.Description != nil {
+		input.Description = *row.Description
+	}
+	if err := binding.Validator.ValidateStruct(&input); err != nil {
+		details := utils.FieldErrors(err)
+		if details == nil {
+			return err
+		}
+
+		messages := make([]string, len(details))
+		for i, detail := range details {
+			messages[i] = detail.Field + ": " + detail.Message
+		}
+		return errors.New(strings.Join(messages, "; "))
+	}
+
+	return nil
+}
+
+// countingReader tracks how many bytes of the import have been consumed
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (cr *countingReader) Read(p []byte) (int, error) {
+	n, err := cr.r.Read(p)
+	cr.n += int64(n)
+	return n, err
+}
+
+// RunProductImport streams rows from r into the catalog, upserting products by SKU.
+// Row-level problems are recorded against the job and do not stop the import; the job
+// is marked failed only when the input itself cannot be read.
+func RunProductImport(job *models.ProductImportJob, r io.Reader) error {
+	job.Status = "running"
+	if err := database.DB.Omit(clause.Associations).Save(job).Error; err != nil {
+		return err
+	}
+
+	counter := &countingReader{r: r}
+	err := readProductRows(counter, job.Format, func(rowNumber int, row ProductImportRow, rowErr error) error {
+		job.ProcessedRows++
+		if rowErr == nil {
+			rowErr = row.Validate()
+		}
+		if rowErr == nil {
+			var created bool
+			created, rowErr = upsertProduct(row, job)
+			if rowErr == nil && created {
+				job.CreatedRows++
+			} else if rowErr == nil {
+				job.UpdatedRows++
+			}
+		}
+
+		if rowErr != nil {
+			job.FailedRows++
+			rowError := models.ProductImportError{JobID: job.ID, Row: rowNumber, SKU: row.SKU, Message: rowErr.Error()}
+			if err := database.DB.Create(&rowError).Error; err != nil {
+				return err
+			}
+		}
+
+		if job.ProcessedRows%progressEvery == 0 {
+			job.ProcessedBytes = counter.n
+			return database.DB.Omit(clause.Associations).Save(job).Error
+		}
+		return nil
+	})
+
+	now := time.Now()
+	job.FinishedAt = &now
+	job.ProcessedBytes = counter.n
+	job.Status = "completed"
+	if err != nil {
+		job.Status = "failed"
+		job.Error = err.Error()
+	}
+
+	if saveErr := database.DB.Omit(clause.Associations).Save(job).Error; saveErr != nil {
+		logrus.WithError(saveErr).WithField("job_id", job.ID).Error("Failed to save product import job")
+	}
+
+	return err
+}
+
+// upsertProduct creates or updates the product with the row's SKU.
+// In dry-run mode it only reports which of the two would have happened.
+func upsertProduct(row ProductImportRow, job *models.ProductImportJob) (bool, error) {
+	created := false
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		var product models.Product
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("sku = ?", row.SKU).First(&product).Error
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+		created = errors.Is(err, gorm.ErrRecordNotFound)
+		// New products must carry every required attribute, just as when created through the API;
+		// updates without attributes keep the ones the product has
+		if created || row.Attributes != nil {
+			if err := validateRowAttributes(tx, row.Attributes); err != nil {
+				return err
+			}
+		}
+		if job.DryRun {
+			return nil
+		}
+
+		if created {
+			// Imported products start as drafts, like products created through the API
+			product = models.Product{SKU: row.SKU, Name: row.Name, Price: row.Price, Status: ProductDraft, Attributes: row.Attributes}
+			if row.Description != nil {
+				product.Description = *row.Description
+			}
+			if row.Stock != nil {
+				product.Stock = *row.Stock
+			}
+			if err := AssignSlug(tx, &product); err != nil {
+				return err
+			}
+			if err := tx.Create(&product).Error; err != nil {
+				return err
+			}
+			return SyncDefaultStockLevel(tx, product, 0, product.Stock, importStockChange(job))
+		}
+
+		// Updates writes the new values back into product, so keep what is being replaced
+		oldPrice, oldStock := product.Price, product.Stock
+		updates := map[string]interface{}{
+			"name":    row.Name,
+			"price":   row.Price,
+			"version": gorm.Expr("version + 1"),
+		}
+		if row.Description != nil {
+			updates["description"] = *row.Description
+		}
+		if row.Stock != nil {
+			updates["stock"] = *row.Stock
+		}
+		if product.Name != row.Name {
+			product.Name = row.Name
+			if err := AssignSlug(tx, &product); err != nil {
+				return err
+			}
+			updates["slug"] = product.Slug
+		}
+		if row.Attributes != nil {
+			// Map updates bypass the field serializer, so encode the JSONB value here
+			encoded, err := json.Marshal(row.Attributes)
+			if err != nil {
+				return err
+			}
+			updates["attributes"] = string(encoded)
+		}
+		if err := tx.Model(&product).Updates(updates).Error; err != nil {
+			return err
+		}
+
+		if err := RecordPriceChange(tx, product.ID, oldPrice, row.Price, PriceSourceImport, &job.CreatedBy, nil); err != nil {
+			return err
+		}
+
+		if row.Stock == nil {
+			return nil
+		}
+		product.Name, product.Stock = row.Name, *row.Stock
+		if err := SyncDefaultStockLevel(tx, product, oldStock, product.Stock, importStockChange(job)); err != nil {
+			return err
+		}
+		return AfterStockChange(tx, product, oldStock)
+	})
+
+	return created, err
+}
+
+// importStockChange attributes stock set by an import to the job and the admin who started it
+func importStockChange(job *models.ProductImportJob) StockChange {
+	return StockChange{Reason: MovementImport, ActorID: &job.CreatedBy, Reference: fmt.Sprintf("import job %d", job.ID)}
+}
+
+// validateRowAttributes turns attribute validation problems into a single row error
+func validateRowAttributes(tx *gorm.DB, attributes map[string]interface{}) error {
+	problems, err := ValidateAttributes(tx, attributes)
+	if err != nil || len(problems) == 0 {
+		return err
+	}
+
+	messages := make([]string, len(problems))
+	for i, problem := range problems {
+		messages[i] = problem.Key + ": " + problem.Message
+	}
+	return errors.New("invalid attributes: " + strings.Join(messages, "; "))
+}
+
+// readProductRows decodes r in the given format and calls fn once per data row.
+// Decoding errors that only affect one row are passed to fn; fn returning an error aborts the read.
+func readProductRows(r io.Reader, format string, fn func(rowNumber int, row ProductImportRow, rowErr error) error) error {
+	switch format {
+	case FormatCSV:
+		return readProductCSV(r, fn)
+	case FormatNDJSON:
+		return readProductNDJSON(r, fn)
+	default:
+		return fmt.Errorf("unsupported import format %q", format)
+	}
+}
+
+func readProductCSV(r io.Reader, fn func(int, ProductImportRow, error) error) error {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return fmt.Errorf("failed to read CSV header: %w", err)
+	}
+
+	columns := map[string]int{}
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, required := range []string{"sku", "name", "price"} {
+		if _, ok := columns[required]; !ok {
+			return fmt.Errorf("CSV header is missing the %q column", required)
+		}
+	}
+
+	for rowNumber := 1; ; rowNumber++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return nil
+		}
+
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			if err := fn(rowNumber, ProductImportRow{}, err); err != nil {
+				return err
+			}
+			continue
+		} else if err != nil {
+			return err
+		}
+
+		row, rowErr := parseCSVRecord(record, columns)
+		if err := fn(rowNumber, row, rowErr); err != nil {
+			return err
+		}
+	}
+}
+
+func parseCSVRecord(record []string, columns map[string]int) (ProductImportRow, error) {
+	field := func(name string) string {
+		i, ok := columns[name]
+		if !ok || i >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[i])
+	}
+
+	// Optional columns that are missing or left empty leave the product's value alone
+	row := ProductImportRow{
+		SKU:  field("sku"),
+		Name: field("name"),
+	}
+	if description := field("description"); description != "" {
+		row.Description = &description
+	}
+
+	price, err := strconv.ParseFloat(field("price"), 64)
+	if err != nil {
+		return row, fmt.Errorf("invalid price %q", field("price"))
+	}
+	row.Price = price
+
+	if stock := field("stock"); stock != "" {
+		parsed, err := strconv.Atoi(stock)
+		if err != nil {
+			return row, fmt.Errorf("invalid stock %q", stock)
+		}
+		row.Stock = &parsed
+	}
+
+	return row, nil
+}
+
+func readProductNDJSON(r io.Reader, fn func(int, ProductImportRow, error) error) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	rowNumber := 0
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		rowNumber++
+		var row ProductImportRow
+		decoder := json.NewDecoder(strings.NewReader(line))
+		decoder.DisallowUnknownFields()
+		rowErr := decoder.Decode(&row)
+		if rowErr != nil {
+			rowErr = fmt.Errorf("invalid JSON: %w", rowErr)
+		}
+		if err := fn(rowNumber, row, rowErr); err != nil {
+			return err
+		}
+	}
+
+	return scanner.Err()
+}
+
+// ExportProducts streams the whole catalog to w in the given format, flushing after each batch
+func ExportProducts(w io.Writer, format string, flush func()) error {
+	var csvWriter *csv.Writer
+	encoder := json.NewEncoder(w)
+	switch format {
+	case FormatCSV:
+		csvWriter = csv.NewWriter(w)
+		if err := csvWriter.Write(ProductCSVHeader); err != nil {
+			return err
+		}
+	case FormatNDJSON:
+	default:
+		return fmt.Errorf("unsupported export format %q", format)
+	}
+
+	var batch []models.Product
+	return database.DB.Order("id").FindInBatches(&batch, 500, func(tx *gorm.DB, _ int) error {
+		for _, product := range batch {
+			var err error
+			if csvWriter != nil {
+				err = csvWriter.Write([]string{
+					product.SKU,
+					product.Name,
+					product.Description,
+					strconv.FormatFloat(product.Price, 'f', -1, 64),
+					strconv.Itoa(product.Stock),
+				})
+			} else {
+				err = encoder.Encode(ProductImportRow{
+					SKU:         product.SKU,
+					Name:        product.Name,
+					Description: &product.Description,
+					Price:       product.Price,
+					Stock:       &product.Stock,
+					Attributes:  product.Attributes,
+				})
+			}
+			if err != nil {
+				return err
+			}
+		}
+
+		if csvWriter != nil {
+			csvWriter.Flush()
+			if err := csvWriter.Error(); err != nil {
+				return err
+			}
+		}
+		flush()
+		return nil
+	}).Error
+}
@@ -0,0 +1,91 @@
+package services
+
+import (
+	"go-ecommerce-api/database"
+	"go-ecommerce-api/models"
+
+	"gorm.io/gorm"
+)
+
+// Recommendation is a product suggested alongside another one
+type Recommendation struct {
+	Product models.Product `json:"product"`
+	Score   int            `json:"score"`  // Orders in which both products were bought together
+	Pinned  bool           `json:"pinned"` // Chosen by an admin rather than computed
+}
+
+// RefreshProductAffinities rebuilds the co-purchase table from every order item that shares
+// an order with another product. Cancelled orders are ignored.
+func RefreshProductAffinities() error {
+	return database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("DELETE FROM product_affinities").Error; err != nil {
+			return err
+		}
+
+		return tx.Exec(`
+			INSERT INTO product_affinities (product_id, related_product_id, score, updated_at)
+			SELECT a.product_id, b.product_id, COUNT(DISTINCT a.order_id), NOW()
+			FROM order_items a
+			JOIN order_items b ON b.order_id = a.order_id AND b.product_id <> a.product_id
+			JOIN orders o ON o.id = a.order_id
+			WHERE o.status NOT IN ? AND a.bundle_item_id IS NULL AND b.bundle_item_id IS NULL
+			GROUP BY a.product_id, b.product_id`, []string{OrderCancelled, OrderRefunded}).Error
+	})
+}
+
+// RecommendationsFor returns up to limit products to suggest with productID: pinned
+// overrides first in their configured order, then the strongest co-purchase affinities.
+// Excluded products never appear.
+func RecommendationsFor(productID uint, limit int) ([]Recommendation, error) {
+	var overrides []models.RecommendationOverride
+	if err := database.DB.Where("product_id = ?", productID).Order("position, id").Find(&overrides).Error; err != nil {
+		return nil, err
+	}
+
+	skip := []uint{productID}
+	var pinned []uint
+	for _, override := range overrides {
+		skip = append(skip, override.RelatedProductID)
+		if override.Action == "pin" {
+			pinned = append(pinned, override.RelatedProductID)
+		}
+	}
+
+	recommendations := []Recommendation{}
+	if len(pinned) > 0 {
+		var products []models.Product
+		if err := database.DB.Scopes(PublishedProducts).Where("id IN ?", pinned).Find(&products).Error; err != nil {
+			return nil, err
+		}
+
+		byID := make(map[uint]models.Product, len(products))
+		for _, product := range products {
+			byID[product.ID] = product
+		}
+		for _, id := range pinned {
+			if product, ok := byID[id]; ok && len(recommendations) < limit {
+				recommendations = append(recommendations, Recommendation{Product: product, Pinned: true})
+			}
+		}
+	}
+
+	remaining := limit - len(recommendations)
+	if remaining <= 0 {
+		return recommendations, nil
+	}
+
+	var affinities []models.ProductAffinity
+	if err := database.DB.Preload("RelatedProduct").
+		Joins("JOIN products ON products.id = product_affinities.related_product_id").Scopes(PublishedProducts).
+		Where("product_id = ? AND related_product_id NOT IN ?", productID, skip).
+		Order("score DESC, related_product_id").Limit(remaining).
+		Find(&affinities).Error; err != nil {
+		return nil, err
+	}
+
+	for _, affinity := range affinities {
+		recommendations = append(recommendations, Recommendation{Product: affinity.RelatedProduct, Score: affinity.Score})
+	}
+
+	return recommendations, nil
+}
@@ -0,0 +1,258 @@
+package services
+
+import (
+	"os"
+	"time"
+
+	"go-ecommerce-api/database"
+	"go-ecommerce-api/models"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Reservation states
+const (
+	ReservationActive    = "active"
+	ReservationCommitted = "committed"
+	ReservationReleased  = "released"
+)
+
+// defaultReservationTTL is how long a pending order holds stock when RESERVATION_TTL is not set
+const defaultReservationTTL = 15 * time.Minute
+
+// ReservationTTL is how long a pending order holds its stock before it expires.
+// It is read from RESERVATION_TTL as a Go duration, e.g. "30m".
+func ReservationTTL() time.Duration {
+	if ttl, err := time.ParseDuration(os.Getenv("RESERVATION_TTL")); err == nil && ttl > 0 {
+		return ttl
+	}
+	return defaultReservationTTL
+}
+
+// reservedQuantities sums the active reservations of each of productIDs
+func reservedQuantities(tx *gorm.DB, productIDs []uint) (map[uint]int, error) {
+	var rows []struct {
+		ProductID uint
+		Quantity  int
+	}
+	if len(productIDs) > 0 {
+		if err := tx.Model(&models.StockReservation{}).
+			Select("product_id, SUM(quantity) AS quantity").
+			Where("product_id IN ? AND status = ?", productIDs, ReservationActive).
+			Group("product_id").Scan(&rows).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	reserved := make(map[uint]int, len(rows))
+	for _, row := range rows {
+		reserved[row.ProductID] = row.Quantity
+	}
+	return reserved, nil
+}
+
+// reserveStock holds stock for an order until the reservation TTL passes. allocations maps
+// product ID to warehouse ID to quantity.
+func reserveStock(tx *gorm.DB, orderID uint, allocations map[uint]map[uint]int) error {
+	expiresAt := time.Now().Add(ReservationTTL())
+	for productID, byWarehouse := range allocations {
+		for warehouseID, quantity := range byWarehouse {
+			warehouseID := warehouseID
+			if err := tx.Create(&models.StockReservation{
+				OrderID:     orderID,
+				ProductID:   productID,
+				WarehouseID: &warehouseID,
+				Quantity:    quantity,
+				Status:      ReservationActive,
+				ExpiresAt:   expiresAt,
+			}).Error; err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// CommitReservations turns an order's active reservations into sales, taking the stock for good.
+// Committed reservations are left alone, so it is safe to call on every status change after payment.
+func CommitReservations(tx *gorm.DB, orderID uint) error {
+	var reservations []models.StockReservation
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("order_id = ? AND status = ?", orderID, ReservationActive).
+		Order("product_id").Find(&reservations).Error; err != nil {
+		return err
+	}
+
+	for _, reservation := range reservations {
+		var product models.Product
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&product, reservation.ProductID).Error; err != nil {
+			return err
+		}
+
+		warehouseID, err := reservationWarehouse(tx, reservation)
+		if err != nil {
+			return err
+		}
+		change := StockChange{Reason: MovementSale, OrderID: &reservation.OrderID}
+		if _, err := adjustStockLevel(tx, product.ID, warehouseID, -reservation.Quantity, change); err != nil {
+			return err
+		}
+		if err := changeProductStock(tx, product, -reservation.Quantity); err != nil {
+			return err
+		}
+
+		if err := tx.Model(&reservation).Update("status", ReservationCommitted).Error; err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// RestockReservations puts back the stock an order's committed reservations took, e.g. when a
+// paid order is cancelled before it ships. The reservations are marked released so the stock is
+// only returned once.
+func RestockReservations(tx *gorm.DB, orderID uint, change StockChange) error {
+	var reservations []models.StockReservation
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("order_id = ? AND status = ?", orderID, ReservationCommitted).
+		Order("product_id").Find(&reservations).Error; err != nil {
+		return err
+	}
+
+	for _, reservation := range reservations {
+		var product models.Product
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&product, reservation.ProductID).Error; err != nil {
+			return err
+		}
+
+		warehouseID, err := reservationWarehouse(tx, reservation)
+		if err != nil {
+			return err
+		}
+		if _, err := adjustStockLevel(tx, product.ID, warehouseID, reservation.Quantity, change); err != nil {
+			return err
+		}
+		if err := changeProductStock(tx, product, reservation.Quantity); err != nil {
+			return err
+		}
+
+		if err := tx.Model(&reservation).Update("status", ReservationReleased).Error; err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// reservationWarehouse returns the warehouse a reservation holds stock at. Reservations made
+// before warehouses existed hold it at the default warehouse.
+func reservationWarehouse(tx *gorm.DB, reservation models.StockReservation) (uint, error) {
+	if reservation.WarehouseID != nil {
+		return *reservation.WarehouseID, nil
+	}
+	warehouse, err := defaultWarehouse(tx)
+	return warehouse.ID, err
+}
+
+// ReleaseReservations gives an order's held stock back, e.g. when the order is cancelled
+func ReleaseReservations(tx *gorm.DB, orderID uint) error {
+	var reservations []models.StockReservation
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("order_id = ? AND status = ?", orderID, ReservationActive).
+		Find(&reservations).Error; err != nil {
+		return err
+	}
+	if len(reservations) == 0 {
+		return nil
+	}
+
+	released := make(map[uint]int)
+	ids := make([]uint, len(reservations))
+	for i, reservation := range reservations {
+		released[reservation.ProductID] += reservation.Quantity
+		ids[i] = reservation.ID
+	}
+	if err := tx.Model(&models.StockReservation{}).Where("id IN ?", ids).
+		Update("status", ReservationReleased).Error; err != nil {
+		return err
+	}
+
+	return afterReservationsReleased(tx, released)
+}
+
+// afterReservationsReleased notifies wishlisters of products that released reservations made
+// available to buy again. released maps product ID to the quantity released.
+func afterReservationsReleased(tx *gorm.DB, released map[uint]int) error {
+	productIDs := make([]uint, 0, len(released))
+	for productID := range released {
+		productIDs = append(productIDs, productID)
+	}
+
+	var products []models.Product
+	if err := tx.Where("id IN ?", productIDs).Order("id").Find(&products).Error; err != nil {
+		return err
+	}
+	reserved, err := reservedQuantities(tx, productIDs)
+	if err != nil {
+		return err
+	}
+
+	for _, product := range products {
+		if !product.TracksStock() {
+			continue
+		}
+		available := product.Stock - reserved[product.ID]
+		if available > 0 && available-released[product.ID] <= 0 {
+			if err := notifyBackInStock(tx, product); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// ReleaseExpiredReservations cancels pending orders whose reservations have expired and
+// releases the stock they held
+func ReleaseExpiredReservations() error {
+	var orderIDs []uint
+	if err := database.DB.Model(&models.StockReservation{}).
+		Where("status = ? AND expires_at <= ?", ReservationActive, time.Now()).
+		Distinct().Pluck("order_id", &orderIDs).Error; err != nil {
+		return err
+	}
+
+	for _, orderID := range orderIDs {
+		err := database.DB.Transaction(func(tx *gorm.DB) error {
+			var order models.Order
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&order, orderID).Error; err != nil {
+				return err
+			}
+
+			// An order paid in the meantime has already committed its reservations
+			if order.Status != OrderPending {
+				return nil
+			}
+
+			from := order.Status
+			order.Status = OrderCancelled
+			if err := tx.Model(&order).Updates(map[string]interface{}{
+				"status":  order.Status,
+				"version": gorm.Expr("version + 1"),
+			}).Error; err != nil {
+				return err
+			}
+			return SettleOrderStatus(tx, order, OrderStatusChange{From: from, Role: OrderActorSystem, Note: "reservation expired"})
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(orderIDs) > 0 {
+		logrus.WithField("orders", len(orderIDs)).Info("Released expired stock reservations")
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+package services
+
+import (
+	"errors"
+	"time"
+
+	"go-ecommerce-api/database"
+	"go-ecommerce-api/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrReviewAlreadyModerated is returned when moderating a review that is no longer pending
+var ErrReviewAlreadyModerated = errors.New("review has already been moderated")
+
+// HasPurchased reports whether the user has a completed order containing the product
+func HasPurchased(userID, productID uint) (bool, error) {
+	var count int64
+	err := database.DB.Model(&models.OrderItem{}).
+		Joins("JOIN orders ON orders.id = order_items.order_id").
+		Where("orders.user_id = ? AND orders.status = ? AND order_items.product_id = ?", userID, OrderCompleted, productID).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// ModerateReview approves or rejects a pending review and refreshes the product's cached rating
+func ModerateReview(review *models.Review, status string, adminID uint, note string) error {
+	return database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(review, review.ID).Error; err != nil {
+			return err
+		}
+		if review.Status != "pending" {
+			return ErrReviewAlreadyModerated
+		}
+
+		now := time.Now()
+		review.Status = status
+		review.ModerationNote = note
+		review.ModeratedBy = &adminID
+		review.ModeratedAt = &now
+		if err := tx.Save(review).Error; err != nil {
+			return err
+		}
+
+		return RefreshProductRating(tx, review.ProductID)
+	})
+}
+
+// RefreshProductRating recomputes a product's cached rating average and count from its approved
+// reviews. The product version is bumped so ETags and stale admin writes see the change.
+func RefreshProductRating(tx *gorm.DB, productID uint) error {
+	var summary struct {
+		Average float64
+		Count   int
+	}
+	if err := tx.Model(&models.Review{}).
+		Select("COALESCE(AVG(rating), 0) AS average, COUNT(*) AS count").
+		Where("product_id = ? AND status = ?", productID, "approved").
+		Scan(&summary).Error; err != nil {
+		return err
+	}
+
+	return tx.Model(&models.Product{ID: productID}).Updates(map[string]interface{}{
+		"rating_average": summary.Average,
+		"rating_count":   summary.Count,
+		"version":        gorm.Expr("version + 1"),
+	}).Error
+}
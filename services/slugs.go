@@ -0,0 +1,99 @@
+package services
+
+import (
+	"fmt"
+
+	"go-ecommerce-api/database"
+	"go-ecommerce-api/models"
+	"go-ecommerce-api/utils"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// AssignSlug gives product a unique slug derived from its name. A slug that already matches
+// the name is kept, so slugs stay stable; a replaced slug is kept as a redirect to the product.
+// The product itself is not saved; tx must be a transaction that saves it, since the slugs
+// considered stay locked until it ends.
+func AssignSlug(tx *gorm.DB, product *models.Product) error {
+	base := utils.Slugify(product.Name)
+	if product.Slug == base {
+		return nil
+	}
+
+	slug := base
+	for n := 2; ; n++ {
+		if err := lockSlug(tx, slug); err != nil {
+			return err
+		}
+		taken, err := slugTaken(tx, slug, product.ID)
+		if err != nil {
+			return err
+		}
+		if !taken {
+			break
+		}
+		slug = fmt.Sprintf("%s-%d", base, n)
+	}
+	// A suffixed slug such as "widget-2" is already the best available for the name
+	if slug == product.Slug {
+		return nil
+	}
+
+	// Reclaiming one of the product's own old slugs removes its redirect
+	if err := tx.Where("slug = ? AND product_id = ?", slug, product.ID).Delete(&models.ProductSlugRedirect{}).Error; err != nil {
+		return err
+	}
+
+	if product.Slug != "" && product.ID != 0 {
+		if err := tx.Create(&models.ProductSlugRedirect{Slug: product.Slug, ProductID: product.ID}).Error; err != nil {
+			return err
+		}
+	}
+
+	product.Slug = slug
+	return nil
+}
+
+// lockSlug holds slug for the rest of the transaction, so that concurrent requests giving
+// products the same name wait for each other instead of both claiming the slug
+func lockSlug(tx *gorm.DB, slug string) error {
+	return tx.Exec("SELECT pg_advisory_xact_lock(hashtext(?))", "product-slug:"+slug).Error
+}
+
+// slugTaken reports whether slug belongs to another product, either currently or as a redirect
+func slugTaken(tx *gorm.DB, slug string, productID uint) (bool, error) {
+	var products, redirects int64
+	if err := tx.Model(&models.Product{}).Where("slug = ? AND id <> ?", slug, productID).Count(&products).Error; err != nil {
+		return false, err
+	}
+	if err := tx.Model(&models.ProductSlugRedirect{}).Where("slug = ? AND product_id <> ?", slug, productID).Count(&redirects).Error; err != nil {
+		return false, err
+	}
+	return products+redirects > 0, nil
+}
+
+// BackfillProductSlugs assigns slugs to products created before slugs existed
+func BackfillProductSlugs() error {
+	var products []models.Product
+	if err := database.DB.Where("slug IS NULL OR slug = ''").Find(&products).Error; err != nil {
+		return err
+	}
+
+	for i := range products {
+		err := database.DB.Transaction(func(tx *gorm.DB) error {
+			if err := AssignSlug(tx, &products[i]); err != nil {
+				return err
+			}
+			return tx.Model(&products[i]).UpdateColumn("slug", products[i].Slug).Error
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(products) > 0 {
+		logrus.WithField("count", len(products)).Info("Backfilled product slugs")
+	}
+	return nil
+}
@@ -0,0 +1,432 @@
+package services
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"go-ecommerce-api/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Stock count states
+const (
+	CountOpen      = "open"
+	CountApplied   = "applied"
+	CountCancelled = "cancelled"
+)
+
+// Stock count errors
+var (
+	// ErrCountClosed is returned when an applied or cancelled count is changed
+	ErrCountClosed = errors.New("stock count is not open")
+
+	// ErrInvalidCount is returned when counted quantities are rejected
+	ErrInvalidCount = errors.New("invalid stock count")
+)
+
+// CountEntry is one counted quantity as uploaded, identifying the product by ID or SKU
+type CountEntry struct {
+	Row       int    `json:"-"` // 1-based position in the upload, for error messages
+	ProductID uint   `json:"product_id"`
+	SKU       string `json:"sku"`
+	Quantity  int    `json:"quantity"`
+}
+
+// CountProblem explains why one uploaded entry was rejected
+type CountProblem struct {
+	Row       int    `json:"row,omitempty"` // Not set for problems found when applying the count
+	ProductID uint   `json:"product_id,omitempty"`
+	SKU       string `json:"sku,omitempty"`
+	Message   string `json:"message"`
+}
+
+// CountError lists every uploaded entry that was rejected; nothing is recorded when there are any
+type CountError struct {
+	Problems []CountProblem
+}
+
+func (e *CountError) Error() string {
+	messages := make([]string, len(e.Problems))
+	for i, problem := range e.Problems {
+		if problem.Row == 0 {
+			messages[i] = fmt.Sprintf("product %d: %s", problem.ProductID, problem.Message)
+			continue
+		}
+		messages[i] = fmt.Sprintf("row %d: %s", problem.Row, problem.Message)
+	}
+	return strings.Join(messages, "; ")
+}
+
+func (e *CountError) Unwrap() error {
+	return ErrInvalidCount
+}
+
+// lockOpenCount locks a stock count so uploads, applying and cancelling cannot interleave,
+// and checks that it is still open
+func lockOpenCount(tx *gorm.DB, countID uint) (models.StockCount, error) {
+	var count models.StockCount
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&count, countID).Error; err != nil {
+		return count, err
+	}
+	if count.Status != CountOpen {
+		return count, fmt.Errorf("%w: it is %s", ErrCountClosed, count.Status)
+	}
+	return count, nil
+}
+
+// RecordCounts adds counted quantities to an open stock count. Recounting a product replaces its
+// earlier quantity. Entries are checked together and any problem rejects the whole upload.
+func RecordCounts(tx *gorm.DB, countID uint, entries []CountEntry) ([]models.StockCountLine, error) {
+	if _, err := lockOpenCount(tx, countID); err != nil {
+		return nil, err
+	}
+
+	lines := make([]models.StockCountLine, 0, len(entries))
+	seen := make(map[uint]int, len(entries))
+	var problems []CountProblem
+	for _, entry := range entries {
+		problem := CountProblem{Row: entry.Row, ProductID: entry.ProductID, SKU: entry.SKU}
+
+		var product models.Product
+		query := tx.Select("id", "sku", "name", "is_digital", "is_bundle")
+		var err error
+		switch {
+		case entry.ProductID != 0:
+			err = query.First(&product, entry.ProductID).Error
+		case entry.SKU != "":
+			err = query.Where("sku = ?", entry.SKU).First(&product).Error
+		default:
+			problem.Message = "product_id or sku is required"
+			problems = append(problems, problem)
+			continue
+		}
+
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			problem.Message = "product not found"
+		case err != nil:
+			return nil, err
+		case !product.TracksStock():
+			problem.Message = fmt.Sprintf("%q does not track stock", product.Name)
+		case entry.Quantity < 0:
+			problem.Message = "quantity must not be negative"
+		case seen[product.ID] != 0:
+			problem.Message = fmt.Sprintf("%q is already counted in row %d", product.Name, seen[product.ID])
+		}
+		if problem.Message != "" {
+			problems = append(problems, problem)
+			continue
+		}
+
+		seen[product.ID] = entry.Row
+		lines = append(lines, models.StockCountLine{CountID: countID, ProductID: product.ID, Counted: entry.Quantity})
+	}
+
+	if len(problems) > 0 {
+		return nil, &CountError{Problems: problems}
+	}
+	if len(lines) == 0 {
+		return lines, nil
+	}
+
+	err := tx.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "count_id"}, {Name: "product_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"counted", "updated_at"}),
+	}).Create(&lines).Error
+	return lines, err
+}
+
+// ReadCountCSV reads counted quantities from CSV with a quantity column and a product_id or sku
+// column. Rows that cannot be parsed are all reported together in a *CountError.
+func ReadCountCSV(r io.Reader) ([]CountEntry, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("%w: failed to read CSV header: %v", ErrInvalidCount, err)
+	}
+
+	columns := map[string]int{}
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	_, hasID := columns["product_id"]
+	_, hasSKU := columns["sku"]
+	if _, ok := columns["quantity"]; !ok || (!hasID && !hasSKU) {
+		return nil, fmt.Errorf("%w: CSV header needs a quantity column and a product_id or sku column", ErrInvalidCount)
+	}
+
+	field := func(record []string, name string) string {
+		i, ok := columns[name]
+		if !ok || i >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[i])
+	}
+
+	var entries []CountEntry
+	var problems []CountProblem
+	for row := 1; ; row++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			problems = append(problems, CountProblem{Row: row, Message: parseErr.Err.Error()})
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+
+		entry := CountEntry{Row: row, SKU: field(record, "sku")}
+		if id := field(record, "product_id"); id != "" {
+			parsed, err := strconv.ParseUint(id, 10, 64)
+			if err != nil {
+				problems = append(problems, CountProblem{Row: row, SKU: entry.SKU, Message: fmt.Sprintf("invalid product_id %q", id)})
+				continue
+			}
+			entry.ProductID = uint(parsed)
+		}
+		if entry.Quantity, err = strconv.Atoi(field(record, "quantity")); err != nil {
+			problems = append(problems, CountProblem{Row: row, ProductID: entry.ProductID, SKU: entry.SKU,
+				Message: fmt.Sprintf("invalid quantity %q", field(record, "quantity"))})
+			continue
+		}
+		entries = append(entries, entry)
+	}
+
+	if len(problems) > 0 {
+		return nil, &CountError{Problems: problems}
+	}
+	return entries, nil
+}
+
+// CountVariance compares the counted quantity of one product with the system's stock level
+type CountVariance struct {
+	ProductID uint   `json:"product_id"`
+	SKU       string `json:"sku"`
+	Name      string `json:"name"`
+	Counted   int    `json:"counted"`
+	Expected  int    `json:"expected"`
+	Variance  int    `json:"variance"` // Counted less Expected; applying the count moves stock by this much
+	Reserved  int    `json:"reserved"` // Held at the warehouse for pending orders; not reported for applied counts
+
+	// BelowReserved is set when the counted quantity cannot cover what is reserved at the warehouse,
+	// beyond what the product may be backordered; such a count is rejected when applied
+	BelowReserved bool `json:"below_reserved,omitempty"`
+}
+
+// VarianceReport is the difference between a stock count and the stock levels it would replace
+type VarianceReport struct {
+	Count         models.StockCount `json:"count"`
+	Lines         []CountVariance   `json:"lines"`
+	Discrepancies int               `json:"discrepancies"` // Lines whose variance is not zero
+	NetVariance   int               `json:"net_variance"`  // Sum of the variances, in units
+}
+
+// StockCountVariance reports each counted product against its stock level at the count's
+// warehouse, largest discrepancies first. Applied counts report the levels they replaced.
+func StockCountVariance(tx *gorm.DB, countID uint) (VarianceReport, error) {
+	report := VarianceReport{Lines: []CountVariance{}}
+	if err := tx.Preload("Warehouse").First(&report.Count, countID).Error; err != nil {
+		return report, err
+	}
+
+	var lines []models.StockCountLine
+	if err := tx.Preload("Product").Where("count_id = ?", countID).Order("product_id").Find(&lines).Error; err != nil {
+		return report, err
+	}
+
+	levels := map[uint]int{}
+	reserved := map[uint]int{}
+	if report.Count.Status != CountApplied && len(lines) > 0 {
+		productIDs := make([]uint, len(lines))
+		for i, line := range lines {
+			productIDs[i] = line.ProductID
+		}
+		var rows []models.StockLevel
+		if err := tx.Where("warehouse_id = ? AND product_id IN ?", report.Count.WarehouseID, productIDs).Find(&rows).Error; err != nil {
+			return report, err
+		}
+		for _, row := range rows {
+			levels[row.ProductID] = row.Quantity
+		}
+		var err error
+		if reserved, err = reservedAtWarehouse(tx, report.Count.WarehouseID, productIDs); err != nil {
+			return report, err
+		}
+	}
+
+	for _, line := range lines {
+		expected := levels[line.ProductID]
+		if line.Expected != nil {
+			expected = *line.Expected
+		}
+		variance := CountVariance{
+			ProductID: line.ProductID,
+			SKU:       line.Product.SKU,
+			Name:      line.Product.Name,
+			Counted:   line.Counted,
+			Expected:  expected,
+			Variance:  line.Counted - expected,
+			Reserved:  reserved[line.ProductID],
+		}
+		variance.BelowReserved = !coversReservations(line.Product, line.Counted, variance.Reserved)
+		report.Lines = append(report.Lines, variance)
+		if variance.Variance != 0 {
+			report.Discrepancies++
+		}
+		report.NetVariance += variance.Variance
+	}
+
+	sort.SliceStable(report.Lines, func(i, j int) bool {
+		return abs(report.Lines[i].Variance) > abs(report.Lines[j].Variance)
+	})
+	return report, nil
+}
+
+// ApplyStockCount sets the stock level of every counted product at the count's warehouse to the
+// counted quantity, recording each difference as an adjustment in the inventory ledger, and closes
+// the count. Products that were not counted are left alone. It must run in a transaction so the
+// count is applied entirely or not at all.
+func ApplyStockCount(tx *gorm.DB, countID, actorID uint) (models.StockCount, error) {
+	count, err := lockOpenCount(tx, countID)
+	if err != nil {
+		return count, err
+	}
+
+	var lines []models.StockCountLine
+	if err := tx.Where("count_id = ?", countID).Order("product_id").Find(&lines).Error; err != nil {
+		return count, err
+	}
+
+	if len(lines) > 0 {
+		// Lock every counted product up front, in ID order, so the levels read below stay current
+		productIDs := make([]uint, len(lines))
+		for i, line := range lines {
+			productIDs[i] = line.ProductID
+		}
+		var products []models.Product
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id IN ?", productIDs).Order("id").Find(&products).Error; err != nil {
+			return count, err
+		}
+
+		// Reserved stock is promised to pending orders, so a count that cannot cover it would drive
+		// the level negative once they are paid
+		reserved, err := reservedAtWarehouse(tx, count.WarehouseID, productIDs)
+		if err != nil {
+			return count, err
+		}
+		byID := make(map[uint]models.Product, len(products))
+		for _, product := range products {
+			byID[product.ID] = product
+		}
+		var problems []CountProblem
+		for _, line := range lines {
+			product := byID[line.ProductID]
+			if !coversReservations(product, line.Counted, reserved[line.ProductID]) {
+				problems = append(problems, CountProblem{ProductID: product.ID, SKU: product.SKU,
+					Message: fmt.Sprintf("counted %d of %q but %d are reserved for pending orders", line.Counted, product.Name, reserved[line.ProductID])})
+			}
+		}
+		if len(problems) > 0 {
+			return count, &CountError{Problems: problems}
+		}
+
+		var levels []models.StockLevel
+		if err := tx.Where("warehouse_id = ? AND product_id IN ?", count.WarehouseID, productIDs).Find(&levels).Error; err != nil {
+			return count, err
+		}
+		expected := make(map[uint]int, len(levels))
+		for _, level := range levels {
+			expected[level.ProductID] = level.Quantity
+		}
+
+		change := StockChange{Reason: MovementAdjustment, ActorID: &actorID, Reference: fmt.Sprintf("stock count %d", count.ID), Note: count.Note}
+		for _, line := range lines {
+			before := expected[line.ProductID]
+			variance := line.Counted - before
+			if _, err := SetStockLevel(tx, line.ProductID, count.WarehouseID, line.Counted, change); err != nil {
+				return count, err
+			}
+			if err := tx.Model(&line).Updates(map[string]interface{}{"expected": before, "variance": variance}).Error; err != nil {
+				return count, err
+			}
+		}
+	}
+
+	now := time.Now()
+	count.Status = CountApplied
+	count.AppliedBy = &actorID
+	count.AppliedAt = &now
+	err = tx.Model(&count).Updates(map[string]interface{}{
+		"status":     count.Status,
+		"applied_by": count.AppliedBy,
+		"applied_at": count.AppliedAt,
+	}).Error
+	return count, err
+}
+
+// reservedAtWarehouse sums the active reservations of each of productIDs held at a warehouse.
+// Reservations made before warehouses existed are held at the default warehouse.
+func reservedAtWarehouse(tx *gorm.DB, warehouseID uint, productIDs []uint) (map[uint]int, error) {
+	warehouse, err := defaultWarehouse(tx)
+	if err != nil {
+		return nil, err
+	}
+
+	var rows []struct {
+		ProductID uint
+		Quantity  int
+	}
+	err = tx.Model(&models.StockReservation{}).
+		Select("product_id, SUM(quantity) AS quantity").
+		Where("product_id IN ? AND status = ?", productIDs, ReservationActive).
+		Where("warehouse_id = ? OR (warehouse_id IS NULL AND ? = ?)", warehouseID, warehouseID, warehouse.ID).
+		Group("product_id").Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	reserved := make(map[uint]int, len(rows))
+	for _, row := range rows {
+		reserved[row.ProductID] = row.Quantity
+	}
+	return reserved, nil
+}
+
+// coversReservations reports whether counted units of product, together with what it may be
+// backordered, cover the units reserved for it
+func coversReservations(product models.Product, counted, reserved int) bool {
+	allowance := oversellAllowance(product)
+	return allowance < 0 || counted+allowance >= reserved
+}
+
+// CancelStockCount closes an open stock count without touching any stock
+func CancelStockCount(tx *gorm.DB, countID uint) (models.StockCount, error) {
+	count, err := lockOpenCount(tx, countID)
+	if err != nil {
+		return count, err
+	}
+
+	count.Status = CountCancelled
+	return count, tx.Model(&count).Update("status", count.Status).Error
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
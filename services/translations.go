@@ -0,0 +1,86 @@
+package services
+
+import (
+	"go-ecommerce-api/models"
+	"go-ecommerce-api/utils"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Localize replaces the name and description of products with their translations for the
+// first locale in chain that has one. Each field falls back along the chain separately and
+// finally to the untranslated content, which is in the default locale.
+func Localize(tx *gorm.DB, products []models.Product, chain []string) error {
+	defaultLocale := utils.DefaultLocale()
+	for i := range products {
+		products[i].Locale = defaultLocale
+	}
+	if len(products) == 0 || len(chain) == 0 {
+		return nil
+	}
+
+	ids := make([]uint, len(products))
+	for i, product := range products {
+		ids[i] = product.ID
+	}
+
+	var translations []models.ProductTranslation
+	if err := tx.Where("product_id IN ? AND locale IN ?", ids, chain).Find(&translations).Error; err != nil {
+		return err
+	}
+
+	byProduct := make(map[uint]map[string]models.ProductTranslation)
+	for _, translation := range translations {
+		if byProduct[translation.ProductID] == nil {
+			byProduct[translation.ProductID] = make(map[string]models.ProductTranslation)
+		}
+		byProduct[translation.ProductID][translation.Locale] = translation
+	}
+
+	for i := range products {
+		product := &products[i]
+		available := byProduct[product.ID]
+		nameDone, descriptionDone := false, false
+		for _, locale := range chain {
+			translation, ok := available[locale]
+			if !ok {
+				continue
+			}
+			if !nameDone && translation.Name != "" {
+				product.Name = translation.Name
+				product.Locale = locale
+				nameDone = true
+			}
+			if !descriptionDone && translation.Description != "" {
+				product.Description = translation.Description
+				descriptionDone = true
+			}
+		}
+	}
+
+	return nil
+}
+
+// SetTranslation creates or replaces a product's translation for one locale.
+// The product's version is bumped because its localized representation changed.
+func SetTranslation(tx *gorm.DB, translation *models.ProductTranslation) error {
+	if err := tx.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "product_id"}, {Name: "locale"}},
+		DoUpdates: clause.AssignmentColumns([]string{"name", "description", "updated_at"}),
+	}).Create(translation).Error; err != nil {
+		return err
+	}
+
+	return tx.Model(&models.Product{}).Where("id = ?", translation.ProductID).UpdateColumn("version", gorm.Expr("version + 1")).Error
+}
+
+// DeleteTranslation removes a product's translation for one locale, reporting whether it existed
+func DeleteTranslation(tx *gorm.DB, productID uint, locale string) (bool, error) {
+	result := tx.Where("product_id = ? AND locale = ?", productID, locale).Delete(&models.ProductTranslation{})
+	if result.Error != nil || result.RowsAffected == 0 {
+		return false, result.Error
+	}
+
+	return true, tx.Model(&models.Product{}).Where("id = ?", productID).UpdateColumn("version", gorm.Expr("version + 1")).Error
+}
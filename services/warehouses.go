@@ -0,0 +1,316 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"go-ecommerce-api/database"
+	"go-ecommerce-api/models"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// DefaultWarehouseCode identifies the warehouse that stock set on the product itself belongs to
+const DefaultWarehouseCode = "DEFAULT"
+
+// Fulfillment strategies, chosen with FULFILLMENT_STRATEGY
+const (
+	// FulfillByPriority takes each product from the highest priority warehouses that have it,
+	// splitting a line across warehouses when needed
+	FulfillByPriority = "priority"
+
+	// FulfillFromSingle ships the whole order from the highest priority warehouse that can fill
+	// all of it, falling back to FulfillByPriority when none can
+	FulfillFromSingle = "single"
+)
+
+// Stock level errors
+var (
+	// ErrStockHeldElsewhere is returned when a product's total stock is lowered below what the
+	// other warehouses hold, which can only be done through their own stock levels
+	ErrStockHeldElsewhere = errors.New("stock is held in other warehouses")
+
+	// ErrInvalidStockLevel is returned when stock levels are set for products that do not track
+	// stock, or lowered below what active reservations hold
+	ErrInvalidStockLevel = errors.New("invalid stock level")
+)
+
+// FulfillmentStrategy returns the configured location selection strategy
+func FulfillmentStrategy() string {
+	if os.Getenv("FULFILLMENT_STRATEGY") == FulfillFromSingle {
+		return FulfillFromSingle
+	}
+	return FulfillByPriority
+}
+
+// EnsureDefaultWarehouse creates the default warehouse if it is missing and moves the stock of
+// products that have no stock levels yet into it
+func EnsureDefaultWarehouse() error {
+	warehouse := models.Warehouse{Code: DefaultWarehouseCode}
+	if err := database.DB.Where(&warehouse).Attrs(models.Warehouse{Name: "Default warehouse"}).FirstOrCreate(&warehouse).Error; err != nil {
+		return err
+	}
+
+	result := database.DB.Exec(`
+		INSERT INTO stock_levels (warehouse_id, product_id, quantity, updated_at)
+		SELECT ?, p.id, p.stock, NOW()
+		FROM products p
+		WHERE NOT p.is_digital AND NOT p.is_bundle
+		AND NOT EXISTS (SELECT 1 FROM stock_levels l WHERE l.product_id = p.id)`, warehouse.ID)
+	if result.Error != nil {
+		return result.Error
+	}
+
+	if result.RowsAffected > 0 {
+		logrus.WithField("count", result.RowsAffected).Info("Backfilled default warehouse stock levels")
+	}
+	return nil
+}
+
+// defaultWarehouse loads the warehouse created by EnsureDefaultWarehouse
+func defaultWarehouse(tx *gorm.DB) (models.Warehouse, error) {
+	var warehouse models.Warehouse
+	err := tx.Where("code = ?", DefaultWarehouseCode).First(&warehouse).Error
+	return warehouse, err
+}
+
+// SyncDefaultStockLevel applies a change of a product's total stock from previous to total to its
+// level at the default warehouse, so the levels keep adding up to Product.Stock. Like SetStockLevel,
+// it will not lower the level below the reservations held there. The caller writes the product's
+// stock itself and must hold its row lock.
+func SyncDefaultStockLevel(tx *gorm.DB, product models.Product, previous, total int, change StockChange) error {
+	if !product.TracksStock() || previous == total {
+		return nil
+	}
+
+	warehouse, err := defaultWarehouse(tx)
+	if err != nil {
+		return err
+	}
+
+	var level models.StockLevel
+	if err := tx.Where("warehouse_id = ? AND product_id = ?", warehouse.ID, product.ID).Limit(1).Find(&level).Error; err != nil {
+		return err
+	}
+	quantity := level.Quantity + total - previous
+	if quantity < 0 {
+		return fmt.Errorf("%w: at least %d must remain", ErrStockHeldElsewhere, previous-level.Quantity)
+	}
+	if total < previous {
+		reserved, err := reservedAtWarehouse(tx, warehouse.ID, []uint{product.ID})
+		if err != nil {
+			return err
+		}
+		if !coversReservations(product, quantity, reserved[product.ID]) {
+			return fmt.Errorf("%w: %d of %q are reserved at the default warehouse", ErrInvalidStockLevel, reserved[product.ID], product.Name)
+		}
+	}
+
+	_, err = adjustStockLevel(tx, product.ID, warehouse.ID, total-previous, change)
+	return err
+}
+
+// SetStockLevel sets a product's stock at one warehouse and updates its total stock to match.
+// The level may not be lowered below the reservations held there, less any oversell allowance.
+func SetStockLevel(tx *gorm.DB, productID, warehouseID uint, quantity int, change StockChange) (models.StockLevel, error) {
+	var product models.Product
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&product, productID).Error; err != nil {
+		return models.StockLevel{}, err
+	}
+	if !product.TracksStock() {
+		return models.StockLevel{}, fmt.Errorf("%w: %q does not track stock", ErrInvalidStockLevel, product.Name)
+	}
+
+	var current models.StockLevel
+	if err := tx.Where("warehouse_id = ? AND product_id = ?", warehouseID, productID).Limit(1).Find(&current).Error; err != nil {
+		return models.StockLevel{}, err
+	}
+
+	delta := quantity - current.Quantity
+	// Lowering stock must leave enough for the orders holding it; raising it always helps
+	if delta < 0 {
+		reserved, err := reservedAtWarehouse(tx, warehouseID, []uint{productID})
+		if err != nil {
+			return models.StockLevel{}, err
+		}
+		if !coversReservations(product, quantity, reserved[productID]) {
+			return models.StockLevel{}, fmt.Errorf("%w: %d of %q are reserved at this warehouse", ErrInvalidStockLevel, reserved[productID], product.Name)
+		}
+	}
+
+	level, err := adjustStockLevel(tx, productID, warehouseID, delta, change)
+	if err != nil {
+		return level, err
+	}
+	return level, changeProductStock(tx, product, delta)
+}
+
+// allocateStock decides which warehouses the quantities of an order are taken from, returning
+// product ID to warehouse ID to quantity. Units in oversold are ordered beyond stock and are
+// taken from the default warehouse, where restocking makes them up. The product rows must
+// already be locked.
+func allocateStock(tx *gorm.DB, quantities map[uint]int, oversold map[uint]int) (map[uint]map[uint]int, error) {
+	allocations := make(map[uint]map[uint]int, len(quantities))
+	if len(quantities) == 0 {
+		return allocations, nil
+	}
+
+	inStock := make(map[uint]int, len(quantities))
+	for productID, quantity := range quantities {
+		inStock[productID] = quantity - oversold[productID]
+	}
+	quantities = inStock
+
+	free, warehouses, err := freeStockByWarehouse(tx, quantities)
+	if err != nil {
+		return nil, err
+	}
+
+	if FulfillmentStrategy() == FulfillFromSingle {
+		for _, warehouse := range warehouses {
+			fits := true
+			for productID, quantity := range quantities {
+				if free[productID][warehouse.ID] < quantity {
+					fits = false
+					break
+				}
+			}
+			if fits {
+				for productID, quantity := range quantities {
+					allocations[productID] = map[uint]int{}
+					if quantity > 0 {
+						allocations[productID][warehouse.ID] = quantity
+					}
+				}
+				return allocations, allocateOversold(tx, allocations, oversold)
+			}
+		}
+	}
+
+	for productID, quantity := range quantities {
+		allocations[productID] = map[uint]int{}
+		for _, warehouse := range warehouses {
+			if quantity == 0 {
+				break
+			}
+			take := min(quantity, free[productID][warehouse.ID])
+			if take > 0 {
+				allocations[productID][warehouse.ID] = take
+				quantity -= take
+			}
+		}
+		if quantity > 0 {
+			return nil, fmt.Errorf("%w: warehouse stock levels of product %d are %d short", ErrInsufficientStock, productID, quantity)
+		}
+	}
+
+	return allocations, allocateOversold(tx, allocations, oversold)
+}
+
+// allocateOversold adds the units ordered beyond stock to the default warehouse's allocations
+func allocateOversold(tx *gorm.DB, allocations map[uint]map[uint]int, oversold map[uint]int) error {
+	if len(oversold) == 0 {
+		return nil
+	}
+
+	warehouse, err := defaultWarehouse(tx)
+	if err != nil {
+		return err
+	}
+	for productID, quantity := range oversold {
+		allocations[productID][warehouse.ID] += quantity
+	}
+	return nil
+}
+
+// freeStockByWarehouse returns the unreserved stock of each product at each warehouse, and the
+// warehouses in fulfillment order
+func freeStockByWarehouse(tx *gorm.DB, quantities map[uint]int) (map[uint]map[uint]int, []models.Warehouse, error) {
+	productIDs := make([]uint, 0, len(quantities))
+	for productID := range quantities {
+		productIDs = append(productIDs, productID)
+	}
+
+	var warehouses []models.Warehouse
+	if err := tx.Order("priority, id").Find(&warehouses).Error; err != nil {
+		return nil, nil, err
+	}
+	fallback, err := defaultWarehouse(tx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var levels []models.StockLevel
+	if err := tx.Where("product_id IN ?", productIDs).Find(&levels).Error; err != nil {
+		return nil, nil, err
+	}
+
+	var reservations []models.StockReservation
+	if err := tx.Where("product_id IN ? AND status = ?", productIDs, ReservationActive).Find(&reservations).Error; err != nil {
+		return nil, nil, err
+	}
+
+	free := make(map[uint]map[uint]int, len(productIDs))
+	for _, productID := range productIDs {
+		free[productID] = map[uint]int{}
+	}
+	for _, level := range levels {
+		free[level.ProductID][level.WarehouseID] += level.Quantity
+	}
+	for _, reservation := range reservations {
+		warehouseID := fallback.ID
+		if reservation.WarehouseID != nil {
+			warehouseID = *reservation.WarehouseID
+		}
+		free[reservation.ProductID][warehouseID] -= reservation.Quantity
+	}
+
+	return free, warehouses, nil
+}
+
+// WarehouseStock is a product's stock at one warehouse
+type WarehouseStock struct {
+	Warehouse models.Warehouse `json:"warehouse"`
+	Quantity  int              `json:"quantity"`
+	Reserved  int              `json:"reserved"`
+	Available int              `json:"available"`
+	Ledger    int              `json:"ledger"` // Stock derived from the inventory ledger; equals Quantity unless levels were changed outside it
+}
+
+// ProductStockByWarehouse lists a product's stock, reservations and availability at every warehouse
+func ProductStockByWarehouse(tx *gorm.DB, productID uint) ([]WarehouseStock, error) {
+	free, warehouses, err := freeStockByWarehouse(tx, map[uint]int{productID: 0})
+	if err != nil {
+		return nil, err
+	}
+
+	var levels []models.StockLevel
+	if err := tx.Where("product_id = ?", productID).Find(&levels).Error; err != nil {
+		return nil, err
+	}
+	quantities := make(map[uint]int, len(levels))
+	for _, level := range levels {
+		quantities[level.WarehouseID] = level.Quantity
+	}
+
+	balances, err := LedgerBalances(tx, productID)
+	if err != nil {
+		return nil, err
+	}
+
+	stock := make([]WarehouseStock, len(warehouses))
+	for i, warehouse := range warehouses {
+		available := free[productID][warehouse.ID]
+		stock[i] = WarehouseStock{
+			Warehouse: warehouse,
+			Quantity:  quantities[warehouse.ID],
+			Reserved:  quantities[warehouse.ID] - available,
+			Available: max(available, 0),
+			Ledger:    balances[warehouse.ID],
+		}
+	}
+	return stock, nil
+}
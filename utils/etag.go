@@ -0,0 +1,54 @@
+package utils
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// ETag builds a strong entity tag for a single versioned resource
+func ETag(id uint, version uint) string {
+	return fmt.Sprintf("\"%d-%d\"", id, version)
+}
+
+// CollectionETag builds a weak entity tag for a list of resources from their id/version pairs
+func CollectionETag(parts []string) string {
+	hash := sha1.Sum([]byte(strings.Join(parts, ",")))
+	return "W/\"" + hex.EncodeToString(hash[:]) + "\""
+}
+
+// VariantETag extends the entity tag of a resource with what selects one of its representations,
+// such as the locale it is written in, so that every representation gets its own tag
+func VariantETag(etag string, parts ...string) string {
+	hash := sha1.Sum([]byte(strings.Join(parts, ",")))
+	return strings.TrimSuffix(etag, "\"") + "+" + hex.EncodeToString(hash[:4]) + "\""
+}
+
+// MatchesETag reports whether an If-Match or If-None-Match header value matches the given tag.
+// Weak and strong tags are compared by their opaque value, and "*" matches any tag.
+func MatchesETag(header string, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// MatchesResourceETag is MatchesETag for preconditions on writes: the representation part a
+// VariantETag adds is ignored, so a tag from any representation of the resource matches it
+func MatchesResourceETag(header string, etag string) bool {
+	candidates := strings.Split(header, ",")
+	for i, candidate := range candidates {
+		candidate = strings.TrimSpace(candidate)
+		if variant := strings.LastIndex(candidate, "+"); variant != -1 && strings.HasSuffix(candidate, "\"") {
+			candidate = candidate[:variant] + "\""
+		}
+		candidates[i] = candidate
+	}
+
+	return MatchesETag(strings.Join(candidates, ","), etag)
+}
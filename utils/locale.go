@@ -0,0 +1,64 @@
+package utils
+
+import (
+	"os"
+
+	"golang.org/x/text/language"
+)
+
+// anyLanguage is what ParseAcceptLanguage turns a "*" entry into
+var anyLanguage = language.Make("mul")
+
+// DefaultLocale is the locale product content is written in when it has no translation.
+// It is read from DEFAULT_LOCALE and defaults to English.
+func DefaultLocale() string {
+	if locale, err := ParseLocale(os.Getenv("DEFAULT_LOCALE")); err == nil {
+		return locale
+	}
+	return "en"
+}
+
+// ParseLocale validates a BCP 47 language tag and returns it in canonical form, e.g. "pt-br" becomes "pt-BR"
+func ParseLocale(value string) (string, error) {
+	tag, err := language.Parse(value)
+	if err != nil {
+		return "", err
+	}
+	return tag.String(), nil
+}
+
+// LocaleChain lists the locales to try for a request, most preferred first: the explicit locale
+// parameter, then the Accept-Language entries by quality, each followed by its more general
+// parents (e.g. "pt-BR" then "pt"). The chain stops at the default locale, since untranslated
+// content is already in that language. Unparseable input is ignored.
+func LocaleChain(param string, acceptLanguage string) []string {
+	var tags []language.Tag
+	if tag, err := language.Parse(param); err == nil {
+		tags = append(tags, tag)
+	}
+	if accepted, _, err := language.ParseAcceptLanguage(acceptLanguage); err == nil {
+		for _, tag := range accepted {
+			// A "*" entry accepts anything, which the default content already satisfies
+			if tag != anyLanguage {
+				tags = append(tags, tag)
+			}
+		}
+	}
+
+	defaultLocale := DefaultLocale()
+	seen := map[string]bool{}
+	var chain []string
+	for _, tag := range tags {
+		for ; tag != language.Und; tag = tag.Parent() {
+			locale := tag.String()
+			if locale == defaultLocale {
+				return chain
+			}
+			if !seen[locale] {
+				seen[locale] = true
+				chain = append(chain, locale)
+			}
+		}
+	}
+	return chain
+}
@@ -0,0 +1,46 @@
+package utils
+
+import (
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+)
+
+// maxSlugLength leaves room for a numeric suffix within the slug column
+const maxSlugLength = 120
+
+// Slugify turns a display name into a lowercase, hyphen-separated URL segment,
+// e.g. "Crème Brûlée Set (6 pcs)" becomes "creme-brulee-set-6-pcs"
+func Slugify(name string) string {
+	stripAccents := transform.Chain(norm.NFD, runes.Remove(runes.In(unicode.Mn)), norm.NFC)
+	plain, _, err := transform.String(stripAccents, name)
+	if err != nil {
+		plain = name
+	}
+
+	var b strings.Builder
+	pendingHyphen := false
+	for _, r := range strings.ToLower(plain) {
+		if r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)) {
+			if pendingHyphen && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			b.WriteRune(r)
+			pendingHyphen = false
+		} else {
+			pendingHyphen = true
+		}
+	}
+
+	slug := b.String()
+	if len(slug) > maxSlugLength {
+		slug = strings.TrimRight(slug[:maxSlugLength], "-")
+	}
+	if slug == "" {
+		return "product"
+	}
+	return slug
+}
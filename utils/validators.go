@@ -0,0 +1,101 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+// skuPattern allows 3-64 uppercase letters, digits, hyphens and underscores, starting with a letter or digit
+var skuPattern = regexp.MustCompile(`^[A-Z0-9][A-Z0-9_-]{2,63}$`)
+
+// FieldError describes a single invalid field in a request body
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// RegisterValidators adds the custom binding rules to gin's validator and makes
+// validation errors report JSON field names. It must run before any request is bound.
+func RegisterValidators() {
+	v, ok := binding.Validator.Engine().(*validator.Validate)
+	if !ok {
+		panic(errors.New("Unexpected binding validator engine."))
+	}
+
+	v.RegisterTagNameFunc(func(field reflect.StructField) string {
+		name := strings.SplitN(field.Tag.Get("json"), ",", 2)[0]
+		if name == "-" || name == "" {
+			return field.Name
+		}
+		return name
+	})
+
+	v.RegisterValidation("price", validatePrice)
+	v.RegisterValidation("sku", validateSKU)
+	v.RegisterValidation("notblank", validateNotBlank)
+}
+
+// validatePrice accepts amounts with at most two decimal places
+func validatePrice(fl validator.FieldLevel) bool {
+	formatted := strconv.FormatFloat(fl.Field().Float(), 'f', -1, 64)
+	if i := strings.IndexByte(formatted, '.'); i >= 0 {
+		return len(formatted)-i-1 <= 2
+	}
+	return true
+}
+
+func validateSKU(fl validator.FieldLevel) bool {
+	return skuPattern.MatchString(fl.Field().String())
+}
+
+func validateNotBlank(fl validator.FieldLevel) bool {
+	return strings.TrimSpace(fl.Field().String()) != ""
+}
+
+// FieldErrors turns a binding error into per-field details.
+// It returns nil when err is not a validation error, e.g. malformed JSON.
+func FieldErrors(err error) []FieldError {
+	var validationErrors validator.ValidationErrors
+	if !errors.As(err, &validationErrors) {
+		return nil
+	}
+
+	details := make([]FieldError, len(validationErrors))
+	for i, fe := range validationErrors {
+		details[i] = FieldError{Field: fe.Field(), Rule: fe.Tag(), Message: fieldErrorMessage(fe)}
+	}
+	return details
+}
+
+func fieldErrorMessage(fe validator.FieldError) string {
+	switch fe.Tag() {
+	case "required":
+		return "is required"
+	case "notblank":
+		return "must not be blank"
+	case "gte":
+		return "must be greater than or equal to " + fe.Param()
+	case "gt":
+		return "must be greater than " + fe.Param()
+	case "lte":
+		return "must be less than or equal to " + fe.Param()
+	case "max":
+		return "must be at most " + fe.Param() + " characters long"
+	case "oneof":
+		return "must be one of " + strings.ReplaceAll(fe.Param(), " ", ", ")
+	case "price":
+		return "must have at most 2 decimal places"
+	case "sku":
+		return "must be 3-64 uppercase letters, digits, hyphens or underscores"
+	default:
+		return fmt.Sprintf("failed the %q rule", fe.Tag())
+	}
+}